@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// backupsDir holds copies of kubeconfig files from before sk changed them.
+	backupsDir = "backups"
+	// journalFile has a JSON line per change sk made to the kubeconfig,
+	// oldest first, pointing at the backups taken for it.
+	journalFile = "journal.jsonl"
+	// maxJournalEntries is how many changes can be undone. Backups of older
+	// changes are removed.
+	maxJournalEntries = 20
+)
+
+// journalEntry is a change sk made to the kubeconfig.
+type journalEntry struct {
+	Time    time.Time       `json:"time"`
+	Files   []journaledFile `json:"files"`
+	Changes []fieldChange   `json:"changes"`
+}
+
+// journaledFile is a kubeconfig file changed by sk, with the checksums of its
+// content before and after. Backup is empty when the file didn't exist.
+type journaledFile struct {
+	Path   string `json:"path"`
+	Backup string `json:"backup,omitempty"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after"`
+}
+
+// fieldChange is a single value sk changed: "current-context" or
+// "contexts.<name>.namespace".
+type fieldChange struct {
+	Field   string `json:"field"`
+	Context string `json:"context,omitempty"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+const (
+	fieldCurrentContext = "current-context"
+	fieldNamespace      = "namespace"
+)
+
+func (f fieldChange) String() string {
+	name := f.Field
+	if f.Context != "" {
+		name = fmt.Sprintf("contexts.%s.%s", f.Context, f.Field)
+	}
+	return fmt.Sprintf("%s: %q -> %q", name, f.From, f.To)
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readKubeConfigFiles returns the content of every existing file in the
+// kubeconfig path.
+func readKubeConfigFiles() (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, p := range splitKubeConfigPath(kubeConfigPath) {
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[p] = data
+	}
+	return files, nil
+}
+
+// modifyKubeConfig writes c to the kubeconfig files like kubectl does, or
+// only patches the changed values when patchKubeConfig is configured. With
+// journal, every file it changes is backed up and the change is added to the
+// journal.
+func modifyKubeConfig(c api.Config, journal bool) error {
+	before, err := readKubeConfigFiles()
+	if err != nil {
+		return err
+	}
+	previous, err := loadConfig().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	changes := fieldChanges(previous, c)
+	if !skCfg.PatchKubeConfig || !patchKubeConfig(previous, c, changes) {
+		err = clientcmd.ModifyConfig(newKubeConfigAccess(kubeConfigPath), c, true)
+	}
+	if !journal {
+		return err
+	}
+	// Files written before a failure are journaled too, so they can be undone
+	return errors.Join(err, journalChange(before, changes))
+}
+
+// fieldChanges lists the current context and namespaces that differ between
+// the two configs.
+func fieldChanges(from, to api.Config) []fieldChange {
+	changes := []fieldChange{}
+	if from.CurrentContext != to.CurrentContext {
+		changes = append(changes, fieldChange{Field: fieldCurrentContext, From: from.CurrentContext, To: to.CurrentContext})
+	}
+	for _, name := range sortedKeys(to.Contexts) {
+		fromCtx := from.Contexts[name]
+		if fromCtx != nil && fromCtx.Namespace != to.Contexts[name].Namespace {
+			changes = append(changes, fieldChange{Field: fieldNamespace, Context: name, From: fromCtx.Namespace, To: to.Contexts[name].Namespace})
+		}
+	}
+	return changes
+}
+
+// kubeConfigFileFor returns the file change is written to, the same one
+// clientcmd.ModifyConfig picks.
+func kubeConfigFileFor(access kubeConfigAccess, previous api.Config, change fieldChange) string {
+	if change.Field == fieldNamespace {
+		if ctx := previous.Contexts[change.Context]; ctx != nil && ctx.LocationOfOrigin != "" {
+			return ctx.LocationOfOrigin
+		}
+	}
+	return access.GetDefaultFilename()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// journalChange backs up the files that differ from before and records the
+// change. Nothing is recorded when no file changed.
+func journalChange(before map[string][]byte, changes []fieldChange) error {
+	after, err := readKubeConfigFiles()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	entry := journalEntry{Time: now, Changes: changes}
+	if err := os.MkdirAll(path.Join(skDir, backupsDir), 0o700); err != nil {
+		return err
+	}
+	for _, p := range sortedKeys(after) {
+		old, existed := before[p]
+		if existed && bytes.Equal(old, after[p]) {
+			continue
+		}
+		f := journaledFile{Path: p, After: checksum(after[p])}
+		if existed {
+			f.Backup = path.Join(backupsDir, fmt.Sprintf("%s-%d-%s", now.Format("20060102T150405.000000000"), len(entry.Files), filepath.Base(p)))
+			f.Before = checksum(old)
+			if err := os.WriteFile(path.Join(skDir, f.Backup), old, 0o600); err != nil {
+				return err
+			}
+		}
+		entry.Files = append(entry.Files, f)
+	}
+	if len(entry.Files) == 0 {
+		return nil
+	}
+
+	return withStateLock(func() error {
+		entries := append(readJournal(), entry)
+		if len(entries) > maxJournalEntries {
+			for _, old := range entries[:len(entries)-maxJournalEntries] {
+				removeBackups(old)
+			}
+			entries = entries[len(entries)-maxJournalEntries:]
+		}
+		return writeJournal(entries)
+	})
+}
+
+func removeBackups(entry journalEntry) {
+	for _, f := range entry.Files {
+		if f.Backup != "" {
+			_ = os.Remove(path.Join(skDir, f.Backup))
+		}
+	}
+}
+
+// readJournal returns the journal, oldest first. Malformed lines are skipped.
+func readJournal() []journalEntry {
+	data, err := os.ReadFile(path.Join(skDir, journalFile))
+	if err != nil {
+		return nil
+	}
+	entries := []journalEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		e := journalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func writeJournal(entries []journalEntry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return writeFileAtomic(path.Join(skDir, journalFile), buf.Bytes())
+}
+
+// dropRemovedFiles removes the files that no longer exist from the journal,
+// and the changes left without files, as there is nothing to restore. It
+// returns the journal left.
+func dropRemovedFiles() ([]journalEntry, error) {
+	entries := []journalEntry{}
+	err := withStateLock(func() error {
+		all := readJournal()
+		dropped := false
+		for _, e := range all {
+			files := []journaledFile{}
+			for _, f := range e.Files {
+				if _, err := os.Stat(f.Path); os.IsNotExist(err) {
+					removeBackups(journalEntry{Files: []journaledFile{f}})
+					dropped = true
+					continue
+				}
+				files = append(files, f)
+			}
+			if len(files) > 0 {
+				e.Files = files
+				entries = append(entries, e)
+			}
+		}
+		if !dropped {
+			return nil
+		}
+		return writeJournal(entries)
+	})
+	return entries, err
+}
+
+// changedFiles returns the files of entry that were changed after sk wrote
+// them.
+func changedFiles(entry journalEntry) []string {
+	changed := []string{}
+	for _, f := range entry.Files {
+		data, err := os.ReadFile(f.Path)
+		if err != nil || checksum(data) != f.After {
+			changed = append(changed, f.Path)
+		}
+	}
+	return changed
+}
+
+// runUndo implements "sk undo [-force]".
+func runUndo(args []string) error {
+	flags := flag.NewFlagSet("undo", flag.ExitOnError)
+	force := flags.Bool("force", false, "Undo only the values sk changed when a file was changed by something else since")
+	// flags uses ExitOnError, so this never returns an error.
+	_ = flags.Parse(args)
+	if flags.NArg() > 0 {
+		return errors.New("usage: sk undo [-force]")
+	}
+	return undo(*force)
+}
+
+// undo reverts the last change in the journal. Files are restored from their
+// backups, unless one was changed by something else since: then undo refuses,
+// or with force only reverts the values sk changed, where they still have the
+// value sk set. It also refuses in a session or with the overlay, as switches
+// there aren't journaled and the files it would restore are shared by every
+// shell.
+func undo(force bool) error {
+	entries, err := dropRemovedFiles()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return errors.New("nothing to undo")
+	}
+	last := entries[len(entries)-1]
+	if err := checkUndoShared(last); err != nil {
+		return err
+	}
+
+	// The files of the change may not be in the kubeconfig path anymore
+	paths := splitKubeConfigPath(kubeConfigPath)
+	for _, f := range last.Files {
+		paths = append(paths, f.Path)
+	}
+	return withKubeConfigFilesLock(paths, func() error {
+		return withStateLock(func() error {
+			return undoLocked(last.Time, force)
+		})
+	})
+}
+
+// checkUndoShared returns an error telling what undoing entry would change
+// when this shell uses a session, the overlay or its own copy of the
+// kubeconfig from "sk shell".
+func checkUndoShared(entry journalEntry) error {
+	in, outside := "", ""
+	switch {
+	case os.Getenv(shellPinEnv) != "":
+		in, outside = "in sk shell", "sk shell"
+	case activeSession() != "":
+		in, outside = "in a session", "the session"
+	case activeOverlay() != "":
+		in, outside = "with the overlay", "the overlay"
+	default:
+		return nil
+	}
+	files := []string{}
+	for _, f := range entry.Files {
+		files = append(files, f.Path)
+	}
+	return fmt.Errorf("switches %s aren't journaled, so sk undo would revert the last change to %s, which every shell shares. Run it outside %s to do that", in, strings.Join(files, ", "), outside)
+}
+
+// undoLocked undoes the last change in the journal, which has to be the one
+// made at expected.
+func undoLocked(expected time.Time, force bool) error {
+	entries := readJournal()
+	if len(entries) == 0 || !entries[len(entries)-1].Time.Equal(expected) {
+		return errors.New("the journal changed while undoing, try again")
+	}
+	entry := entries[len(entries)-1]
+
+	undone := entry.Changes
+	if changed := changedFiles(entry); len(changed) > 0 {
+		if !force {
+			return fmt.Errorf("%s changed since sk last wrote it, undoing would lose those changes. Use sk undo -force to only revert sk's own changes", strings.Join(changed, ", "))
+		}
+		var err error
+		if undone, err = undoFields(entry); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range entry.Files {
+			if err := restoreBackup(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	removeBackups(entry)
+	if err := writeJournal(entries[:len(entries)-1]); err != nil {
+		return err
+	}
+	if len(undone) == 0 {
+		fmt.Fprintln(os.Stderr, "Nothing undone, the values sk set were all changed since")
+	}
+	for _, c := range undone {
+		fmt.Fprintf(os.Stderr, "Undone %s\n", c)
+	}
+	return nil
+}
+
+func restoreBackup(f journaledFile) error {
+	if f.Backup == "" {
+		// sk created the file
+		return os.Remove(f.Path)
+	}
+	data, err := os.ReadFile(path.Join(skDir, f.Backup))
+	if err != nil {
+		return err
+	}
+	if checksum(data) != f.Before {
+		return fmt.Errorf("backup of %s is damaged", f.Path)
+	}
+	return replaceFile(f.Path, data)
+}
+
+// replaceFile writes data over the content of the file at p, in place like
+// kubectl does. That keeps links to it, e.g. from a dotfiles repository, and
+// its owner and mode, and needs no access to its directory.
+func replaceFile(p string, data []byte) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// undoFields sets every value changed by entry back in the files entry
+// changed, where it still has the value sk set. It returns the changes it
+// reverted.
+func undoFields(entry journalEntry) ([]fieldChange, error) {
+	paths := []string{}
+	for _, f := range entry.Files {
+		if _, err := os.Stat(f.Path); err == nil {
+			paths = append(paths, f.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	orig := kubeConfigPath
+	kubeConfigPath = strings.Join(paths, string(filepath.ListSeparator))
+	defer func() { kubeConfigPath = orig }()
+
+	rawConfig, err := loadConfig().RawConfig()
+	if err != nil {
+		return nil, err
+	}
+	undone := []fieldChange{}
+	for _, c := range entry.Changes {
+		switch {
+		case c.Field == fieldCurrentContext && rawConfig.CurrentContext == c.To:
+			rawConfig.CurrentContext = c.From
+		case c.Field == fieldNamespace && rawConfig.Contexts[c.Context] != nil && rawConfig.Contexts[c.Context].Namespace == c.To:
+			rawConfig.Contexts[c.Context].Namespace = c.From
+		default:
+			continue
+		}
+		undone = append(undone, c)
+	}
+	if len(undone) == 0 {
+		return nil, nil
+	}
+	return undone, modifyKubeConfig(rawConfig, false)
+}
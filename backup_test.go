@@ -0,0 +1,185 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestModifyKubeConfig_BacksUpAndJournalsChanges(t *testing.T) {
+	dir := setSkDir(t)
+	cfgPath := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, cfgPath)
+	original, err := os.ReadFile(cfgPath)
+	require.NoError(t, err)
+
+	require.NoError(t, applyFavorite(loadRawConfig(t), "ctx-b", "payments"))
+
+	entries := readJournal()
+	require.Len(t, entries, 1)
+	assert.Equal(t, []fieldChange{
+		{Field: fieldCurrentContext, From: "ctx-a", To: "ctx-b"},
+		{Field: fieldNamespace, Context: "ctx-b", From: "default", To: "payments"},
+	}, entries[0].Changes)
+	require.Len(t, entries[0].Files, 1)
+	f := entries[0].Files[0]
+	assert.Equal(t, cfgPath, f.Path)
+	backup, err := os.ReadFile(filepath.Join(dir, f.Backup))
+	require.NoError(t, err)
+	assert.Equal(t, string(original), string(backup))
+
+	// A switch that changes nothing isn't journaled
+	require.NoError(t, applyFavorite(loadRawConfig(t), "ctx-b", "payments"))
+	assert.Len(t, readJournal(), 1)
+}
+
+func TestModifyKubeConfig_RotatesBackups(t *testing.T) {
+	dir := setSkDir(t)
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b"))
+
+	for i := 0; i < maxJournalEntries+5; i++ {
+		require.NoError(t, applyContextChange(loadRawConfig(t), []string{"ctx-a", "ctx-b"}[i%2]))
+	}
+
+	assert.Len(t, readJournal(), maxJournalEntries)
+	backups, err := os.ReadDir(filepath.Join(dir, backupsDir))
+	require.NoError(t, err)
+	assert.Len(t, backups, maxJournalEntries)
+}
+
+func TestUndo_RestoresBackup(t *testing.T) {
+	setSkDir(t)
+	cfgPath := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, cfgPath)
+	original, err := os.ReadFile(cfgPath)
+	require.NoError(t, err)
+
+	require.NoError(t, applyContextChange(loadRawConfig(t), "ctx-b"))
+	require.NoError(t, applyNamespaceChange(loadRawConfig(t), "ctx-b", "payments"))
+
+	require.NoError(t, undo(false))
+	assert.Equal(t, "ctx-b", loadRawConfig(t).CurrentContext)
+	assert.Equal(t, "default", loadRawConfig(t).Contexts["ctx-b"].Namespace)
+	require.NoError(t, undo(false))
+	restored, err := os.ReadFile(cfgPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(original), string(restored))
+
+	assert.EqualError(t, undo(false), "nothing to undo")
+}
+
+func TestUndo_RefusesWhenChangedElsewhere(t *testing.T) {
+	setSkDir(t)
+	cfgPath := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b", "ctx-c")
+	setKubeConfigPath(t, cfgPath)
+
+	require.NoError(t, applyFavorite(loadRawConfig(t), "ctx-b", "payments"))
+	// Another tool changes the namespace of another context
+	otherTool := loadRawConfig(t)
+	otherTool.Contexts["ctx-c"].Namespace = "kube-system"
+	require.NoError(t, clientcmd.WriteToFile(otherTool, cfgPath))
+
+	err := undo(false)
+	assert.ErrorContains(t, err, cfgPath+" changed since sk last wrote it")
+	assert.Len(t, readJournal(), 1)
+
+	require.NoError(t, undo(true))
+	cfg := loadRawConfig(t)
+	assert.Equal(t, "ctx-a", cfg.CurrentContext)
+	assert.Equal(t, "default", cfg.Contexts["ctx-b"].Namespace)
+	assert.Equal(t, "kube-system", cfg.Contexts["ctx-c"].Namespace)
+	assert.Empty(t, readJournal())
+}
+
+func TestUndo_RestoresThroughSymlink(t *testing.T) {
+	setSkDir(t)
+	dotfiles := writeKubeConfigFile(t, t.TempDir(), "kubeconfig", "ctx-a", "ctx-a", "ctx-b")
+	link := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.Symlink(dotfiles, link))
+	setKubeConfigPath(t, link)
+	original, err := os.ReadFile(dotfiles)
+	require.NoError(t, err)
+
+	require.NoError(t, applyContextChange(loadRawConfig(t), "ctx-b"))
+	require.NoError(t, undo(false))
+
+	target, err := os.Readlink(link)
+	require.NoError(t, err, "the link is kept")
+	assert.Equal(t, dotfiles, target)
+	restored, err := os.ReadFile(dotfiles)
+	require.NoError(t, err)
+	assert.Equal(t, string(original), string(restored))
+}
+
+func TestUndo_ForceOnlyChangesJournaledFiles(t *testing.T) {
+	setSkDir(t)
+	dir := t.TempDir()
+	journaled := writeKubeConfigFile(t, dir, "journaled", "ctx-a", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, journaled)
+	require.NoError(t, applyContextChange(loadRawConfig(t), "ctx-b"))
+	// Another tool changes something else
+	otherTool := loadRawConfig(t)
+	otherTool.Contexts["ctx-a"].Namespace = "kube-system"
+	require.NoError(t, clientcmd.WriteToFile(otherTool, journaled))
+
+	current := writeKubeConfigFile(t, dir, "current", "ctx-b", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, current)
+	before, err := os.ReadFile(current)
+	require.NoError(t, err)
+
+	require.NoError(t, undo(true))
+
+	after, err := os.ReadFile(current)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+	cfg, err := clientcmd.LoadFromFile(journaled)
+	require.NoError(t, err)
+	assert.Equal(t, "ctx-a", cfg.CurrentContext)
+	assert.Equal(t, "kube-system", cfg.Contexts["ctx-a"].Namespace)
+	assert.Empty(t, readJournal(), "undoing isn't journaled")
+}
+
+func TestUndo_DropsChangesToRemovedFiles(t *testing.T) {
+	setSkDir(t)
+	dir := t.TempDir()
+	gone := writeKubeConfigFile(t, dir, "gone", "ctx-a", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, gone)
+	require.NoError(t, applyContextChange(loadRawConfig(t), "ctx-b"))
+	require.NoError(t, os.Remove(gone))
+
+	current := writeKubeConfigFile(t, dir, "current", "ctx-b", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, current)
+	before, err := os.ReadFile(current)
+	require.NoError(t, err)
+
+	assert.EqualError(t, undo(false), "nothing to undo")
+
+	after, err := os.ReadFile(current)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+	assert.Empty(t, readJournal())
+}
+
+func TestUndo_RefusesInSession(t *testing.T) {
+	setSkDir(t)
+	shared := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, shared)
+	require.NoError(t, applyContextChange(loadRawConfig(t), "ctx-b"))
+	requestSession(t)
+	require.NoError(t, applyContextChange(loadRawConfig(t), "ctx-a"))
+	before, err := os.ReadFile(shared)
+	require.NoError(t, err)
+
+	err = undo(false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), shared)
+
+	after, err := os.ReadFile(shared)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+	assert.Len(t, readJournal(), 1)
+}
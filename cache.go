@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	namespaceCacheDir        = "cache/namespaces"
+	defaultNamespaceCacheTTL = 24 * time.Hour
+)
+
+var (
+	// namespaceCacheTTL is how long cached namespaces are offered without
+	// waiting for the cluster. Set from the config file.
+	namespaceCacheTTL = defaultNamespaceCacheTTL
+	// refreshNamespaces is set by -refresh to skip the cache.
+	refreshNamespaces bool
+)
+
+// namespaceCacheEntry is the namespace list of one cluster, as seen by one
+// user. Contexts sharing both share the entry.
+type namespaceCacheEntry struct {
+	Server     string    `json:"server"`
+	User       string    `json:"user"`
+	FetchedAt  time.Time `json:"fetchedAt"`
+	Namespaces []string  `json:"namespaces"`
+}
+
+// namespaceCacheFile returns the cache file of contextName, keyed by its
+// cluster server and user. The second value is false when the context is
+// missing or incomplete.
+func namespaceCacheFile(rawConfig api.Config, contextName string) (string, namespaceCacheEntry, bool) {
+	ctx := rawConfig.Contexts[contextName]
+	if ctx == nil {
+		return "", namespaceCacheEntry{}, false
+	}
+	cluster := rawConfig.Clusters[ctx.Cluster]
+	if cluster == nil || cluster.Server == "" {
+		return "", namespaceCacheEntry{}, false
+	}
+
+	sum := sha256.Sum256([]byte(cluster.Server + "\x00" + ctx.AuthInfo))
+	file := path.Join(skDir, namespaceCacheDir, hex.EncodeToString(sum[:8])+".yaml")
+	return file, namespaceCacheEntry{Server: cluster.Server, User: ctx.AuthInfo}, true
+}
+
+// readNamespaceCache returns the cached namespaces of contextName, unless
+// -refresh was given or they're older than namespaceCacheTTL.
+func readNamespaceCache(rawConfig api.Config, contextName string) ([]string, bool) {
+	file, key, ok := namespaceCacheFile(rawConfig, contextName)
+	if !ok || refreshNamespaces {
+		return nil, false
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+
+	entry := namespaceCacheEntry{}
+	if err := yaml.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	// Guard against hash collisions and hand-edited files
+	if entry.Server != key.Server || entry.User != key.User {
+		return nil, false
+	}
+	if time.Since(entry.FetchedAt) > namespaceCacheTTL {
+		return nil, false
+	}
+	return entry.Namespaces, true
+}
+
+// writeNamespaceCache stores the complete namespace list of contextName.
+func writeNamespaceCache(rawConfig api.Config, contextName string, namespaces []string) error {
+	file, entry, ok := namespaceCacheFile(rawConfig, contextName)
+	if !ok {
+		return nil
+	}
+	entry.FetchedAt = time.Now().UTC()
+	entry.Namespaces = namespaces
+
+	if err := os.MkdirAll(path.Dir(file), 0o700); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(file, data)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespaceCache_RoundTrip(t *testing.T) {
+	setSkDir(t)
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b"))
+	cfg := loadRawConfig(t)
+
+	require.NoError(t, writeNamespaceCache(cfg, "ctx-a", []string{"default", "payments"}))
+
+	cached, ok := readNamespaceCache(cfg, "ctx-a")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"default", "payments"}, cached)
+
+	// ctx-b is another cluster, so it has its own entry.
+	_, ok = readNamespaceCache(cfg, "ctx-b")
+	assert.False(t, ok)
+}
+
+func TestNamespaceCache_SharedByContextsOfTheSameClusterAndUser(t *testing.T) {
+	setSkDir(t)
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a"))
+	cfg := loadRawConfig(t)
+	alias := *cfg.Contexts["ctx-a"]
+	alias.Namespace = "other"
+	cfg.Contexts["alias"] = &alias
+
+	require.NoError(t, writeNamespaceCache(cfg, "ctx-a", []string{"default"}))
+
+	cached, ok := readNamespaceCache(cfg, "alias")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"default"}, cached)
+}
+
+func TestNamespaceCache_HonorsTTLAndRefresh(t *testing.T) {
+	setSkDir(t)
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a"))
+	cfg := loadRawConfig(t)
+	require.NoError(t, writeNamespaceCache(cfg, "ctx-a", []string{"default"}))
+
+	origTTL, origRefresh := namespaceCacheTTL, refreshNamespaces
+	t.Cleanup(func() { namespaceCacheTTL, refreshNamespaces = origTTL, origRefresh })
+
+	refreshNamespaces = true
+	_, ok := readNamespaceCache(cfg, "ctx-a")
+	assert.False(t, ok, "-refresh skips the cache")
+
+	refreshNamespaces = false
+	namespaceCacheTTL = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	_, ok = readNamespaceCache(cfg, "ctx-a")
+	assert.False(t, ok, "expired entries aren't used")
+}
+
+func TestNamespaceChoices_WritesCache(t *testing.T) {
+	setSkDir(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(corev1.NamespaceList{
+			TypeMeta: metav1.TypeMeta{Kind: "NamespaceList", APIVersion: "v1"},
+			Items: []corev1.Namespace{
+				{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	setKubeConfigPath(t, writeServerKubeConfig(t, server.URL))
+	cfg := loadRawConfig(t)
+
+	names, complete, err := namespaceChoices(cfg, "restricted")
+	require.NoError(t, err)
+	assert.True(t, complete)
+	assert.Equal(t, []string{"default", "team-a"}, names)
+
+	cached, ok := readNamespaceCache(cfg, "restricted")
+	assert.True(t, ok)
+	assert.Equal(t, names, cached)
+}
+
+func TestCurrentFirst(t *testing.T) {
+	assert.Equal(t, []string{"b", "a", "c"}, currentFirst([]string{"a", "b", "c"}, "b"))
+	assert.Equal(t, []string{"a", "c"}, currentFirst([]string{"a", "c"}, "b"))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const defaultRequestTimeout = 10 * time.Second
+
+// requestTimeout bounds every call sk makes to a cluster. Set from the config
+// file or -timeout.
+var requestTimeout = defaultRequestTimeout
+
+type clusterErrorKind int
+
+const (
+	clusterErrorUnknown clusterErrorKind = iota
+	clusterErrorUnreachable
+	clusterErrorAuthExpired
+	clusterErrorForbidden
+	clusterErrorCertificate
+)
+
+// clusterError wraps an error from talking to a cluster with a message that
+// says what most likely went wrong and what to do about it.
+type clusterError struct {
+	kind        clusterErrorKind
+	contextName string
+	err         error
+}
+
+func (e *clusterError) Error() string {
+	switch e.kind {
+	case clusterErrorUnreachable:
+		return fmt.Sprintf("Cluster of context '%s' is unreachable (%s). Check your network or VPN, or raise -timeout (currently %s)", e.contextName, e.reason(), requestTimeout)
+	case clusterErrorAuthExpired:
+		return fmt.Sprintf("Credentials for context '%s' were rejected or have expired. Log in again and retry", e.contextName)
+	case clusterErrorForbidden:
+		return fmt.Sprintf("Not allowed to list namespaces in context '%s'", e.contextName)
+	case clusterErrorCertificate:
+		return fmt.Sprintf("Certificate of the cluster of context '%s' isn't trusted or doesn't match its address: %s", e.contextName, e.reason())
+	default:
+		return e.err.Error()
+	}
+}
+
+func (e *clusterError) Unwrap() error {
+	return e.err
+}
+
+// reason is the innermost error message, without client-go's request details.
+func (e *clusterError) reason() string {
+	msg := e.err.Error()
+	if i := strings.LastIndex(msg, ": "); i >= 0 {
+		return msg[i+2:]
+	}
+	return msg
+}
+
+// classifyClusterError turns err into a clusterError for contextName. nil and
+// already classified errors are returned as they are.
+func classifyClusterError(contextName string, err error) error {
+	var classified *clusterError
+	if err == nil || errors.As(err, &classified) {
+		return err
+	}
+	return &clusterError{kind: clusterErrorKindOf(err), contextName: contextName, err: err}
+}
+
+func clusterErrorKindOf(err error) clusterErrorKind {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var verification *tls.CertificateVerificationError
+	var netErr net.Error
+	var dnsErr *net.DNSError
+
+	switch {
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostname), errors.As(err, &invalid), errors.As(err, &verification):
+		return clusterErrorCertificate
+	case apierrors.IsUnauthorized(err), strings.Contains(err.Error(), "getting credentials"):
+		return clusterErrorAuthExpired
+	case apierrors.IsForbidden(err):
+		return clusterErrorForbidden
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH), errors.As(err, &dnsErr), errors.As(err, &netErr) && netErr.Timeout():
+		return clusterErrorUnreachable
+	default:
+		return clusterErrorUnknown
+	}
+}
+
+// withSpinner runs fn while showing a spinner and msg on stderr, if stderr is
+// a terminal.
+func withSpinner(msg string, fn func()) {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		frames := []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			fmt.Fprintf(os.Stderr, "\r%c %s", frames[i%len(frames)], msg)
+			select {
+			case <-done:
+				// Clear the line again
+				fmt.Fprint(os.Stderr, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	fn()
+	close(done)
+	<-stopped
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setRequestTimeout(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	orig := requestTimeout
+	requestTimeout = timeout
+	t.Cleanup(func() { requestTimeout = orig })
+}
+
+func namespaceChoicesError(t *testing.T, server string) *clusterError {
+	t.Helper()
+	setSkDir(t)
+	setKubeConfigPath(t, writeServerKubeConfig(t, server))
+
+	_, _, err := namespaceChoices(loadRawConfig(t), "restricted")
+	var classified *clusterError
+	require.ErrorAs(t, err, &classified)
+	return classified
+}
+
+func TestNamespaceChoices_ClassifiesErrors(t *testing.T) {
+	t.Run("connection refused", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		err := namespaceChoicesError(t, server.URL)
+		assert.Equal(t, clusterErrorUnreachable, err.kind)
+		assert.Contains(t, err.Error(), "unreachable")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		setRequestTimeout(t, 50*time.Millisecond)
+		unblock := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-unblock
+		}))
+		t.Cleanup(server.Close)
+		t.Cleanup(func() { close(unblock) })
+
+		err := namespaceChoicesError(t, server.URL)
+		assert.Equal(t, clusterErrorUnreachable, err.kind)
+		assert.Contains(t, err.Error(), "50ms")
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		t.Cleanup(server.Close)
+
+		err := namespaceChoicesError(t, server.URL)
+		assert.Equal(t, clusterErrorAuthExpired, err.kind)
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		t.Cleanup(server.Close)
+
+		err := namespaceChoicesError(t, server.URL)
+		assert.Equal(t, clusterErrorCertificate, err.kind)
+	})
+}
+
+func TestClassifyClusterError_KeepsUnknownErrorsAsTheyAre(t *testing.T) {
+	err := classifyClusterError("ctx", errors.New("boom"))
+	assert.EqualError(t, err, "boom")
+	assert.NoError(t, classifyClusterError("ctx", nil))
+	assert.Same(t, err, classifyClusterError("ctx", err))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	prompt "github.com/c-bata/go-prompt"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// printCompletions implements the hidden "sk __complete" mode used by the
+// scripts from "sk init". words are the command line up to and including the
+// word being completed, starting with the command name itself.
+func printCompletions(words []string) {
+	for _, c := range completeWords(words) {
+		fmt.Println(c)
+	}
+}
+
+// completeWords returns the candidates for the last word, based on what comes
+// before it: favorite names after -f/-F/-d/-r, a shell after "init", what to
+// do after "config", contexts and favorites after "exec" and "shell", a
+// namespace after a context (or with -N), and a context otherwise.
+func completeWords(words []string) []string {
+	if len(words) < 2 {
+		return nil
+	}
+	current := words[len(words)-1]
+	previous := words[1 : len(words)-1]
+
+	if len(previous) > 0 && previous[0] == "init" {
+		if len(previous) == 1 {
+			return filterCompletions([]string{"bash", "fish", "zsh"}, current)
+		}
+		return nil
+	}
+
+	if len(previous) > 0 && previous[0] == "config" {
+		if len(previous) == 1 {
+			return filterCompletions([]string{"path", "validate", "view"}, current)
+		}
+		return nil
+	}
+
+	if len(previous) > 0 && (previous[0] == "exec" || previous[0] == "shell") {
+		if slices.Contains(previous, "--") || previous[len(previous)-1] == "-n" {
+			return nil
+		}
+		rawConfig, err := loadConfig().RawConfig()
+		if err != nil {
+			return nil
+		}
+		switch {
+		case previous[0] == "exec" || len(previous) == 1:
+			return filterCompletions(append(getContextNames(rawConfig), favoriteNames()...), current)
+		case len(previous) == 2:
+			target, err := resolveExecTarget(rawConfig, readFavorites(), previous[1])
+			if err != nil {
+				return nil
+			}
+			return completeNamespaces(rawConfig, target.Context, current)
+		}
+		return nil
+	}
+
+	if len(previous) > 0 {
+		switch strings.TrimLeft(previous[len(previous)-1], "-") {
+		case "f", "F", "d", "r":
+			return filterCompletions(favoriteNames(), current)
+		}
+	}
+
+	positional, namespaceOnly := []string{}, false
+	for i := 0; i < len(previous); i++ {
+		word := previous[i]
+		if word == "-" || !strings.HasPrefix(word, "-") {
+			if word != "-" {
+				positional = append(positional, word)
+			}
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(word, "-"), "=")
+		if name == "N" {
+			namespaceOnly = true
+		}
+		if !hasValue && flagTakesValue(name) {
+			i++
+		}
+	}
+
+	rawConfig, err := loadConfig().RawConfig()
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case !namespaceOnly && len(positional) == 0:
+		return filterCompletions(getContextNames(rawConfig), current)
+	case namespaceOnly && len(positional) == 0:
+		return completeNamespaces(rawConfig, rawConfig.CurrentContext, current)
+	case !namespaceOnly && len(positional) == 1:
+		contextName, err := matchSelection("context", getContextNames(rawConfig), positional[0])
+		if err != nil {
+			return nil
+		}
+		return completeNamespaces(rawConfig, contextName, current)
+	}
+	return nil
+}
+
+// completeNamespaces lists the namespaces of contextName that match current,
+// from the cache if possible.
+func completeNamespaces(rawConfig api.Config, contextName, current string) []string {
+	if namespaces, ok := readNamespaceCache(rawConfig, contextName); ok {
+		return filterCompletions(namespaces, current)
+	}
+	namespaces, _, err := namespaceChoices(rawConfig, contextName)
+	if err != nil {
+		return nil
+	}
+	return filterCompletions(namespaces, current)
+}
+
+// filterCompletions keeps the candidates starting with current. When there
+// are none it falls back to the fuzzy matching used by the prompt, so shells
+// that replace the word (bash, zsh) still get something useful.
+func filterCompletions(candidates []string, current string) []string {
+	matches := []string{}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, current) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) > 0 {
+		return matches
+	}
+
+	for _, s := range prompt.FilterFuzzy(toSuggestions(candidates), current, true) {
+		matches = append(matches, s.Text)
+	}
+	return matches
+}
+
+// flagTakesValue reports whether the flag name consumes the next word.
+func flagTakesValue(name string) bool {
+	f := flag.CommandLine.Lookup(name)
+	if f == nil {
+		return false
+	}
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return false
+	}
+	return true
+}
+
+func favoriteNames() []string {
+	return sortedFavoriteNames(readFavorites())
+}
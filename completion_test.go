@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompleteWords(t *testing.T) {
+	setSkDir(t)
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "prod-eu", "prod-eu", "prod-us", "staging"))
+	require.NoError(t, saveFavorite("payments", favoriteFrom("prod-eu", "payments"), false))
+
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{"contexts by prefix", []string{"sk", "prod"}, []string{"prod-eu", "prod-us"}},
+		{"contexts after a flag", []string{"sk", "-n", "sta"}, []string{"staging"}},
+		{"fuzzy fallback", []string{"sk", "pdus"}, []string{"prod-us"}},
+		{"favorites after -f", []string{"sk", "-f", ""}, []string{"payments"}},
+		{"config commands", []string{"sk", "config", "v"}, []string{"validate", "view"}},
+		{"shells after init", []string{"sk", "init", "z"}, []string{"zsh"}},
+		{"contexts and favorites after exec", []string{"sk", "exec", "p"}, []string{"prod-eu", "prod-us", "payments"}},
+		{"targets after shell", []string{"sk", "shell", "sta"}, []string{"staging"}},
+		{"nothing after exec --", []string{"sk", "exec", "staging", "--", "k"}, nil},
+		{"nothing past the namespace", []string{"sk", "prod-eu", "default", ""}, nil},
+		{"nothing without words", []string{"sk"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := completeWords(tt.words)
+			if tt.want == nil {
+				assert.Empty(t, got)
+				return
+			}
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
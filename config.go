@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	prompt "github.com/c-bata/go-prompt"
+	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	configPath = resolveConfigPath()
+	// skCfg holds the user's sk configuration, loaded at startup.
+	skCfg skConfig
+)
+
+// skConfig is the optional, hand-written sk configuration file.
+type skConfig struct {
+	// RequestTimeout bounds every request to a cluster, e.g. "5s".
+	RequestTimeout duration `json:"requestTimeout,omitempty"`
+	// NamespaceCacheTTL is how long cached namespaces are shown without
+	// waiting for the cluster, e.g. "1h". "0s" disables the cache.
+	NamespaceCacheTTL *duration `json:"namespaceCacheTTL,omitempty"`
+	// Protected lists glob patterns, e.g. "*prod*", of contexts that need
+	// confirmation before becoming the current context.
+	Protected []string `json:"protected,omitempty"`
+	// ProtectedConfirm is how a switch to a protected context is confirmed:
+	// "yes" for a y/N question, the default, or "name" to type its name.
+	ProtectedConfirm string `json:"protectedConfirm,omitempty"`
+	// ProtectedExpiry switches back to the previous context this long after
+	// switching to a protected one, e.g. "15m". Unset means never.
+	ProtectedExpiry duration `json:"protectedExpiry,omitempty"`
+	// HiddenContexts lists glob patterns, or /regular expressions/, of
+	// contexts left out of prompts, completion and abbreviated names. They
+	// can still be switched to by their full name.
+	HiddenContexts []string `json:"hiddenContexts,omitempty"`
+	// IncludeContexts, when set, leaves out every context not matching one
+	// of its patterns, like HiddenContexts does.
+	IncludeContexts []string `json:"includeContexts,omitempty"`
+	// GroupContextsBy groups the context prompt by "server" host, cloud
+	// "provider" or "region", shown next to each context.
+	GroupContextsBy string `json:"groupContextsBy,omitempty"`
+	// NamespacePrompt also prompts for a namespace after a context was picked
+	// from the prompt, as if -n was given.
+	NamespacePrompt bool `json:"namespacePrompt,omitempty"`
+	// Prompt styles the interactive prompts.
+	Prompt promptConfig `json:"prompt,omitempty"`
+	// KubeConfig is the kubeconfig path to use when $KUBECONFIG isn't set,
+	// instead of ~/.kube/config.
+	KubeConfig string `json:"kubeconfig,omitempty"`
+	// PatchKubeConfig only replaces the changed current-context and namespace
+	// values in the kubeconfig, keeping comments, key order and formatting.
+	// Files that can't be patched are rewritten like kubectl does.
+	PatchKubeConfig bool `json:"patchKubeConfig,omitempty"`
+	// Overlay always keeps the current context and namespaces in an overlay
+	// kubeconfig in the state dir, never writing to the kubeconfig. Without
+	// it the overlay is only used for kubeconfig files that are read-only.
+	Overlay bool `json:"overlay,omitempty"`
+	// StateDir is where sk keeps its state, instead of ~/.sk or
+	// $XDG_STATE_HOME/sk.
+	StateDir string `json:"stateDir,omitempty"`
+	// Contexts holds per-context settings, keyed by context name.
+	Contexts map[string]contextConfig `json:"contexts,omitempty"`
+}
+
+type promptConfig struct {
+	// Prefix is shown in front of the input, " ⎈ " by default.
+	Prefix string `json:"prefix,omitempty"`
+	// MaxSuggestions limits how many suggestions are shown at once. By
+	// default as many as fit in the terminal.
+	MaxSuggestions uint16 `json:"maxSuggestions,omitempty"`
+	// MatchDetails also matches the input against what's shown next to each
+	// suggestion, such as a context's server, user and namespace.
+	MatchDetails bool `json:"matchDetails,omitempty"`
+	// Colors of the suggestion list, by name, e.g. "blue" or "darkgray".
+	PreviewColor                 string `json:"previewColor,omitempty"`
+	SuggestionBackground         string `json:"suggestionBackground,omitempty"`
+	SelectedSuggestionBackground string `json:"selectedSuggestionBackground,omitempty"`
+}
+
+type contextConfig struct {
+	// Namespaces is offered by the namespace prompt when the cluster doesn't
+	// allow listing namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// resolveConfigPath returns $XDG_CONFIG_HOME/sk/config.yaml, defaulting to
+// ~/.config/sk/config.yaml.
+func resolveConfigPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			// best effort
+			return filepath.Join(".config", "sk", "config.yaml")
+		}
+		configHome = filepath.Join(userHome, ".config")
+	}
+	return filepath.Join(configHome, "sk", "config.yaml")
+}
+
+// readConfig reads the config file. A missing file is an empty config.
+func readConfig() (skConfig, error) {
+	c := skConfig{}
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return c, fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+	if err := c.validate(); err != nil {
+		return c, fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+	return c, nil
+}
+
+// promptColors are the color names accepted in the prompt config.
+var promptColors = map[string]prompt.Color{
+	"default":   prompt.DefaultColor,
+	"black":     prompt.Black,
+	"darkred":   prompt.DarkRed,
+	"darkgreen": prompt.DarkGreen,
+	"brown":     prompt.Brown,
+	"darkblue":  prompt.DarkBlue,
+	"purple":    prompt.Purple,
+	"cyan":      prompt.Cyan,
+	"lightgray": prompt.LightGray,
+	"darkgray":  prompt.DarkGray,
+	"red":       prompt.Red,
+	"green":     prompt.Green,
+	"yellow":    prompt.Yellow,
+	"blue":      prompt.Blue,
+	"fuchsia":   prompt.Fuchsia,
+	"turquoise": prompt.Turquoise,
+	"white":     prompt.White,
+}
+
+// withDefaults returns c with every unset value that has a default filled
+// in, as shown by "sk config".
+func (c skConfig) withDefaults() skConfig {
+	if c.RequestTimeout.Duration == 0 {
+		c.RequestTimeout.Duration = defaultRequestTimeout
+	}
+	if c.NamespaceCacheTTL == nil {
+		c.NamespaceCacheTTL = &duration{defaultNamespaceCacheTTL}
+	}
+	if c.ProtectedConfirm == "" {
+		c.ProtectedConfirm = confirmYes
+	}
+	if c.Prompt.Prefix == "" {
+		c.Prompt.Prefix = " ⎈ "
+	}
+	if c.Prompt.PreviewColor == "" {
+		c.Prompt.PreviewColor = "blue"
+	}
+	if c.Prompt.SuggestionBackground == "" {
+		c.Prompt.SuggestionBackground = "darkgray"
+	}
+	if c.Prompt.SelectedSuggestionBackground == "" {
+		c.Prompt.SelectedSuggestionBackground = "lightgray"
+	}
+	if c.KubeConfig == "" {
+		c.KubeConfig = filepath.Join(homedir.HomeDir(), ".kube", "config")
+	}
+	if c.StateDir == "" {
+		c.StateDir = resolveSkDir()
+	}
+	return c
+}
+
+// applyConfig puts the settings of c into effect. $KUBECONFIG takes
+// precedence over the kubeconfig setting.
+func applyConfig(c skConfig) {
+	if c.RequestTimeout.Duration > 0 {
+		requestTimeout = c.RequestTimeout.Duration
+	}
+	if c.NamespaceCacheTTL != nil {
+		namespaceCacheTTL = c.NamespaceCacheTTL.Duration
+	}
+	if c.KubeConfig != "" && os.Getenv("KUBECONFIG") == "" {
+		kubeConfigPath = expandHome(c.KubeConfig)
+	}
+	if c.StateDir != "" {
+		skDir = expandHome(c.StateDir)
+	}
+}
+
+// runConfigCommand implements "sk config [view|validate|path]". view, the
+// default, prints the config with defaults filled in.
+func runConfigCommand(args []string) error {
+	command := "view"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("usage: sk config [view|validate|path]")
+	}
+
+	switch command {
+	case "path":
+		fmt.Println(configPath)
+		return nil
+	case "validate":
+		if _, err := readConfig(); err != nil {
+			return err
+		}
+		fmt.Printf("%s is valid\n", configPath)
+		return nil
+	case "view":
+		c, err := readConfig()
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(c.withDefaults())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# %s\n%s", configPath, data)
+		return nil
+	}
+	return fmt.Errorf("unknown config command %q, use view, validate or path", command)
+}
+
+// expandHome expands a leading "~/" to the home directory.
+func expandHome(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		return filepath.Join(homedir.HomeDir(), rest)
+	}
+	return p
+}
+
+// validate checks the values UnmarshalStrict can't.
+func (c skConfig) validate() error {
+	patterns := []struct {
+		name     string
+		patterns []string
+	}{
+		{"protected", c.Protected},
+		{"hiddenContexts", c.HiddenContexts},
+		{"includeContexts", c.IncludeContexts},
+	}
+	for _, p := range patterns {
+		for _, pattern := range p.patterns {
+			if err := validatePattern(pattern); err != nil {
+				return fmt.Errorf("%s pattern %q: %w", p.name, pattern, err)
+			}
+		}
+	}
+	switch c.GroupContextsBy {
+	case "", groupByServer, groupByProvider, groupByRegion:
+	default:
+		return fmt.Errorf("groupContextsBy must be %q, %q or %q, not %q", groupByServer, groupByProvider, groupByRegion, c.GroupContextsBy)
+	}
+	colors := []struct{ name, value string }{
+		{"previewColor", c.Prompt.PreviewColor},
+		{"suggestionBackground", c.Prompt.SuggestionBackground},
+		{"selectedSuggestionBackground", c.Prompt.SelectedSuggestionBackground},
+	}
+	for _, color := range colors {
+		if _, ok := promptColors[color.value]; color.value != "" && !ok {
+			return fmt.Errorf("prompt %s: unknown color %q", color.name, color.value)
+		}
+	}
+	switch c.ProtectedConfirm {
+	case "", confirmYes, confirmName:
+	default:
+		return fmt.Errorf("protectedConfirm must be %q or %q, not %q", confirmYes, confirmName, c.ProtectedConfirm)
+	}
+	return nil
+}
+
+// duration is a time.Duration written as a string like "1h30m" in the config.
+type duration struct {
+	time.Duration
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations are written like \"30s\" or \"1h\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if parsed < 0 {
+		return fmt.Errorf("duration %q can't be negative", s)
+	}
+	d.Duration = parsed
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig points sk at a config file with the given content.
+func writeConfig(t *testing.T, content string) {
+	t.Helper()
+	orig := configPath
+	configPath = filepath.Join(t.TempDir(), "config.yaml")
+	t.Cleanup(func() { configPath = orig })
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0o600))
+}
+
+func TestReadConfig(t *testing.T) {
+	writeConfig(t, `
+requestTimeout: 3s
+contexts:
+  prod-eu:
+    namespaces: [payments, orders]
+`)
+
+	c, err := readConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, c.RequestTimeout.Duration)
+	assert.Equal(t, []string{"payments", "orders"}, c.Contexts["prod-eu"].Namespaces)
+}
+
+func TestReadConfig_MissingFileIsEmpty(t *testing.T) {
+	orig := configPath
+	configPath = filepath.Join(t.TempDir(), "missing.yaml")
+	t.Cleanup(func() { configPath = orig })
+
+	c, err := readConfig()
+	require.NoError(t, err)
+	assert.Equal(t, skConfig{}, c)
+}
+
+func TestReadConfig_RejectsInvalidValues(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown key":       "requestTimeuot: 3s\n",
+		"numeric duration":  "requestTimeout: 3\n",
+		"negative duration": "requestTimeout: -3s\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			writeConfig(t, content)
+			_, err := readConfig()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestReadConfig_Preferences(t *testing.T) {
+	writeConfig(t, `
+hiddenContexts: ["*-legacy"]
+namespacePrompt: true
+kubeconfig: ~/work/kubeconfig
+stateDir: /tmp/sk-state
+prompt:
+  prefix: "> "
+  maxSuggestions: 5
+  previewColor: green
+`)
+
+	c, err := readConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*-legacy"}, c.HiddenContexts)
+	assert.True(t, c.NamespacePrompt)
+	assert.Equal(t, promptConfig{Prefix: "> ", MaxSuggestions: 5, PreviewColor: "green"}, c.Prompt)
+
+	d := c.withDefaults()
+	assert.Equal(t, "> ", d.Prompt.Prefix)
+	assert.Equal(t, "darkgray", d.Prompt.SuggestionBackground)
+	assert.Equal(t, defaultRequestTimeout, d.RequestTimeout.Duration)
+	assert.Equal(t, "~/work/kubeconfig", d.KubeConfig)
+}
+
+func TestReadConfig_RejectsUnknownColor(t *testing.T) {
+	writeConfig(t, "prompt:\n  suggestionBackground: mauve\n")
+	_, err := readConfig()
+	assert.ErrorContains(t, err, `prompt suggestionBackground: unknown color "mauve"`)
+}
+
+func TestApplyConfig_KubeConfigEnvTakesPrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	origPath, origDir := kubeConfigPath, skDir
+	t.Cleanup(func() { kubeConfigPath, skDir = origPath, origDir })
+
+	t.Setenv("KUBECONFIG", "")
+	applyConfig(skConfig{KubeConfig: "~/work/kubeconfig", StateDir: "~/state"})
+	assert.Equal(t, filepath.Join(home, "work", "kubeconfig"), kubeConfigPath)
+	assert.Equal(t, filepath.Join(home, "state"), skDir)
+
+	kubeConfigPath = "unchanged"
+	t.Setenv("KUBECONFIG", "/from/env")
+	applyConfig(skConfig{KubeConfig: "~/work/kubeconfig"})
+	assert.Equal(t, "unchanged", kubeConfigPath)
+}
+
+func TestResolveSkDir_HonorsXDGStateHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+	assert.Equal(t, filepath.Join(home, "state", "sk"), resolveSkDir())
+
+	// An existing ~/.sk is kept
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".sk"), 0o700))
+	assert.Equal(t, filepath.Join(home, ".sk"), resolveSkDir())
+
+	t.Setenv("XDG_STATE_HOME", "")
+	assert.Equal(t, filepath.Join(home, ".sk"), resolveSkDir())
+}
+
+func TestGetContextNames_LeavesOutHiddenContexts(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{HiddenContexts: []string{"*-legacy"}})
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "current-legacy", "current-legacy", "old-legacy", "prod"))
+	rawConfig := loadRawConfig(t)
+
+	assert.Equal(t, []string{"current-legacy", "prod"}, getContextNames(rawConfig))
+
+	// Still reachable by full name
+	rawConfig = switchContext(rawConfig, "old-legacy")
+	assert.Equal(t, "old-legacy", loadRawConfig(t).CurrentContext)
+}
+
+func TestRunConfigCommand(t *testing.T) {
+	writeConfig(t, "requestTimeout: 3s\n")
+
+	output := captureStdout(t, func() { require.NoError(t, runConfigCommand(nil)) })
+	assert.Contains(t, output, "# "+configPath+"\n")
+	assert.Contains(t, output, "requestTimeout: 3s\n")
+	assert.Contains(t, output, "namespaceCacheTTL: 24h0m0s\n")
+
+	output = captureStdout(t, func() { require.NoError(t, runConfigCommand([]string{"validate"})) })
+	assert.Equal(t, configPath+" is valid\n", output)
+
+	writeConfig(t, "requestTimeout: soon\n")
+	assert.Error(t, runConfigCommand([]string{"validate"}))
+	assert.EqualError(t, runConfigCommand([]string{"edit"}), `unknown config command "edit", use view, validate or path`)
+}
+
+func TestReadConfig_RejectsInvalidFilterSettings(t *testing.T) {
+	writeConfig(t, "includeContexts: [\"/(/\"]\n")
+	_, err := readConfig()
+	assert.ErrorContains(t, err, `includeContexts pattern "/(/"`)
+
+	writeConfig(t, "groupContextsBy: team\n")
+	_, err = readConfig()
+	assert.ErrorContains(t, err, `groupContextsBy must be "server", "provider" or "region", not "team"`)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// execTarget is a context and namespace to run a command in, named after the
+// argument it was resolved from.
+type execTarget struct {
+	Name      string
+	Context   string
+	Namespace string
+}
+
+// runExec implements "sk exec [-n namespace] <target>... -- <command>". Every
+// target is a context or a favorite. The command runs with a temporary
+// kubeconfig in front of $KUBECONFIG that selects the target, so the shared
+// kubeconfig is never written. It returns the exit code to exit with.
+func runExec(args []string) int {
+	usage := "Usage: sk exec [-n namespace] <context|favorite>... -- <command> [args...]"
+	dashes := slices.Index(args, "--")
+	if dashes < 0 || dashes == len(args)-1 {
+		fail(usage)
+	}
+	command := args[dashes+1:]
+
+	flags := flag.NewFlagSet("exec", flag.ExitOnError)
+	namespace := flags.String("n", "", "Namespace to use instead of the one of the context or favorite")
+	// flags uses ExitOnError, so this never returns an error.
+	_ = flags.Parse(args[:dashes])
+	names := []string{}
+	for flags.NArg() > 0 {
+		names = append(names, flags.Arg(0))
+		_ = flags.Parse(flags.Args()[1:])
+	}
+	if len(names) == 0 {
+		fail(usage)
+	}
+
+	rawConfig, err := loadConfig().RawConfig()
+	checkErr(err)
+	targets := []execTarget{}
+	for _, name := range names {
+		target, err := resolveExecTarget(rawConfig, readFavorites(), name)
+		checkErr(err)
+		if *namespace != "" {
+			target.Namespace = *namespace
+		}
+		targets = append(targets, target)
+	}
+
+	if len(targets) == 1 {
+		code, err := execIn(rawConfig, targets[0], command, os.Stdin, os.Stdout, os.Stderr)
+		checkErr(err)
+		return code
+	}
+	return execParallel(rawConfig, targets, command)
+}
+
+// resolveExecTarget looks name up as a context, then as a favorite, and then
+// as an abbreviated context name like "sk <context>" does.
+func resolveExecTarget(rawConfig api.Config, favorites map[string]favorite, name string) (execTarget, error) {
+	if ctx := rawConfig.Contexts[name]; ctx != nil {
+		return execTarget{Name: name, Context: name, Namespace: ctx.Namespace}, nil
+	}
+	if f, ok := favorites[name]; ok {
+		if rawConfig.Contexts[f.Context] == nil {
+			return execTarget{}, fmt.Errorf("favorite %q uses context %q, which is not in the kubeconfig", name, f.Context)
+		}
+		return execTarget{Name: name, Context: f.Context, Namespace: f.Namespace}, nil
+	}
+	contextName, err := matchSelection("context", getContextNames(rawConfig), name)
+	if err != nil {
+		return execTarget{}, err
+	}
+	return execTarget{Name: name, Context: contextName, Namespace: rawConfig.Contexts[contextName].Namespace}, nil
+}
+
+// writeOverrideKubeConfig writes a temporary kubeconfig selecting contextName
+// and namespace. Put in front of the current kubeconfig path it makes
+// kubectl use that context and namespace, and it receives any change kubectl
+// makes to them. The caller removes the file.
+func writeOverrideKubeConfig(rawConfig api.Config, contextName, namespace string) (string, error) {
+	ctx := rawConfig.Contexts[contextName]
+	if ctx == nil {
+		return "", fmt.Errorf("context %q not found in kubeconfig", contextName)
+	}
+	override := api.NewConfig()
+	override.CurrentContext = contextName
+	overrideCtx := *ctx
+	overrideCtx.LocationOfOrigin = ""
+	overrideCtx.Namespace = namespace
+	override.Contexts[contextName] = &overrideCtx
+
+	f, err := os.CreateTemp("", "sk-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err = clientcmd.WriteToFile(*override, f.Name()); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// overrideEnv returns the environment with $KUBECONFIG pointing at override
+// followed by the current kubeconfig path.
+func overrideEnv(override string) []string {
+	paths := append([]string{override}, splitKubeConfigPath(kubeConfigPath)...)
+	env := slices.DeleteFunc(os.Environ(), func(kv string) bool { return strings.HasPrefix(kv, "KUBECONFIG=") })
+	return append(env, "KUBECONFIG="+strings.Join(paths, string(filepath.ListSeparator)))
+}
+
+// execIn runs command in target and returns its exit code. An error means the
+// command couldn't be run at all.
+func execIn(rawConfig api.Config, target execTarget, command []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	override, err := writeOverrideKubeConfig(rawConfig, target.Context, target.Namespace)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(override)
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = overrideEnv(override)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// execParallel runs command in all targets at once, prefixing every line of
+// output with the target name. It returns the first non-zero exit code, in
+// target order.
+func execParallel(rawConfig api.Config, targets []execTarget, command []string) int {
+	width := 0
+	for _, t := range targets {
+		width = max(width, len(t.Name))
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	codes := make([]int, len(targets))
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prefix := fmt.Sprintf("[%-*s] ", width, target.Name)
+			stdout := &prefixWriter{mu: &mu, out: os.Stdout, prefix: prefix}
+			stderr := &prefixWriter{mu: &mu, out: os.Stderr, prefix: prefix}
+			code, err := execIn(rawConfig, target, command, nil, stdout, stderr)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				code = 1
+			}
+			stdout.Flush()
+			stderr.Flush()
+			codes[i] = code
+		}()
+	}
+	wg.Wait()
+
+	for _, code := range codes {
+		if code != 0 {
+			return code
+		}
+	}
+	return 0
+}
+
+// prefixWriter writes complete lines to out, each starting with prefix.
+// Writers sharing mu never interleave within a line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.writeLine(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush writes a trailing incomplete line, if any.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprint(w.out, w.prefix)
+	_, _ = w.out.Write(line)
+}
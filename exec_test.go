@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// printKubeConfig prints the first file in $KUBECONFIG, which is the override
+// written by execIn.
+var printKubeConfig = []string{"sh", "-c", `cat "${KUBECONFIG%%:*}"`}
+
+func TestResolveExecTarget(t *testing.T) {
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "prod-eu", "prod-eu", "staging"))
+	rawConfig := loadRawConfig(t)
+	favorites := map[string]favorite{"payments": favoriteFrom("prod-eu", "payments"), "gone": favoriteFrom("old", "x")}
+
+	target, err := resolveExecTarget(rawConfig, favorites, "staging")
+	require.NoError(t, err)
+	assert.Equal(t, execTarget{Name: "staging", Context: "staging", Namespace: "default"}, target)
+
+	target, err = resolveExecTarget(rawConfig, favorites, "payments")
+	require.NoError(t, err)
+	assert.Equal(t, execTarget{Name: "payments", Context: "prod-eu", Namespace: "payments"}, target)
+
+	target, err = resolveExecTarget(rawConfig, favorites, "stag")
+	require.NoError(t, err)
+	assert.Equal(t, "staging", target.Context)
+
+	_, err = resolveExecTarget(rawConfig, favorites, "gone")
+	assert.EqualError(t, err, `favorite "gone" uses context "old", which is not in the kubeconfig`)
+}
+
+func TestExecIn_UsesOverrideAndLeavesKubeConfigUntouched(t *testing.T) {
+	shared := writeKubeConfigFile(t, t.TempDir(), "config", "prod-eu", "prod-eu", "staging")
+	setKubeConfigPath(t, shared)
+	before, err := os.ReadFile(shared)
+	require.NoError(t, err)
+	rawConfig := loadRawConfig(t)
+
+	var stdout bytes.Buffer
+	code, err := execIn(rawConfig, execTarget{Name: "staging", Context: "staging", Namespace: "payments"}, printKubeConfig, nil, &stdout, os.Stderr)
+	require.NoError(t, err)
+	assert.Equal(t, 0, code)
+
+	override, err := clientcmd.Load(stdout.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "staging", override.CurrentContext)
+	assert.Equal(t, "payments", override.Contexts["staging"].Namespace)
+	assert.Equal(t, "staging", override.Contexts["staging"].Cluster)
+
+	after, err := os.ReadFile(shared)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+}
+
+func TestExecIn_ReturnsExitCode(t *testing.T) {
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "prod-eu", "prod-eu"))
+	rawConfig := loadRawConfig(t)
+
+	code, err := execIn(rawConfig, execTarget{Name: "prod-eu", Context: "prod-eu"}, []string{"sh", "-c", "exit 3"}, nil, os.Stdout, os.Stderr)
+	require.NoError(t, err)
+	assert.Equal(t, 3, code)
+
+	_, err = execIn(rawConfig, execTarget{Name: "prod-eu", Context: "prod-eu"}, []string{"sk-no-such-command"}, nil, os.Stdout, os.Stderr)
+	assert.Error(t, err)
+}
+
+func TestExecParallel_PrefixesOutput(t *testing.T) {
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "prod-eu", "prod-eu", "staging"))
+	rawConfig := loadRawConfig(t)
+	targets := []execTarget{
+		{Name: "prod-eu", Context: "prod-eu", Namespace: "default"},
+		{Name: "staging", Context: "staging", Namespace: "default"},
+	}
+
+	var code int
+	output := captureStdout(t, func() {
+		code = execParallel(rawConfig, targets, []string{"sh", "-c", `printf 'one\ntwo'; [ "$(grep current-context "${KUBECONFIG%%:*}")" = "current-context: prod-eu" ]`})
+	})
+
+	assert.Equal(t, 1, code)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	assert.ElementsMatch(t, []string{"[prod-eu] one", "[prod-eu] two", "[staging] one", "[staging] two"}, lines)
+}
+
+func TestPrefixWriter_WritesWholeLines(t *testing.T) {
+	var out bytes.Buffer
+	w := &prefixWriter{mu: &sync.Mutex{}, out: &out, prefix: "[a] "}
+
+	_, _ = w.Write([]byte("hel"))
+	assert.Empty(t, out.String())
+	_, _ = w.Write([]byte("lo\nwor"))
+	assert.Equal(t, "[a] hello\n", out.String())
+	w.Flush()
+	assert.Equal(t, "[a] hello\n[a] wor\n", out.String())
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	prompt "github.com/c-bata/go-prompt"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// favoritesFile holds every favorite and is written atomically, so a
+	// favorite is always stored or removed as a whole.
+	favoritesFile    = "favorites.yaml"
+	favoritesVersion = 1
+)
+
+type favoritesStore struct {
+	Version   int                 `json:"version"`
+	Favorites map[string]favorite `json:"favorites"`
+}
+
+type favorite struct {
+	Context     string    `json:"context"`
+	Namespace   string    `json:"namespace"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func favoriteFrom(context, namespace string) favorite {
+	return favorite{Context: context, Namespace: namespace, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+}
+
+func printFavorites() {
+	favorites := readFavorites()
+	for _, name := range sortedFavoriteNames(favorites) {
+		f := favorites[name]
+		line := fmt.Sprintf("%s: %s/%s", name, f.Context, f.Namespace)
+		if f.Description != "" {
+			line += " - " + f.Description
+		}
+		if len(f.Tags) > 0 {
+			line += fmt.Sprintf(" [%s]", strings.Join(f.Tags, ", "))
+		}
+		fmt.Println(line)
+	}
+}
+
+// selectFavorite shows a prompt listing every favorite as
+// "name  →  context/namespace" and returns the chosen name.
+func selectFavorite(favorites map[string]favorite) string {
+	if len(favorites) == 0 {
+		fail("No favorites stored yet, use -F <name> to store one")
+	}
+
+	names := sortedFavoriteNames(favorites)
+	suggestions := []prompt.Suggest{}
+	for _, name := range names {
+		suggestions = append(suggestions, prompt.Suggest{Text: name, Description: favoriteDescription(favorites[name])})
+	}
+
+	selection := showSuggestPrompt(suggestions)
+	if !validateSelection(names, selection) {
+		fail(fmt.Sprintf("'%s' is not a valid favorite selection", selection))
+	}
+	return selection
+}
+
+func favoriteDescription(f favorite) string {
+	description := fmt.Sprintf("→  %s/%s", f.Context, f.Namespace)
+	if f.Description != "" {
+		description += "  " + f.Description
+	}
+	return description
+}
+
+// favoriteNotFoundMessage explains that name isn't a favorite, suggesting the
+// closest existing one if there is a likely candidate.
+func favoriteNotFoundMessage(favorites map[string]favorite, name string) string {
+	if suggestion := closestMatch(sortedFavoriteNames(favorites), name); suggestion != "" {
+		return fmt.Sprintf("Favorite '%s' not found, did you mean '%s'?", name, suggestion)
+	}
+	return fmt.Sprintf("Favorite '%s' not found, use -l to list favorites", name)
+}
+
+// readFavorites returns all favorites keyed by name.
+func readFavorites() map[string]favorite {
+	store, err := loadFavorites()
+	checkErr(err)
+	return store.Favorites
+}
+
+// readFavorite returns the favorite stored under name, and whether it exists.
+func readFavorite(name string) (favorite, bool) {
+	f, ok := readFavorites()[name]
+	return f, ok
+}
+
+// saveFavorite stores f under name. An existing favorite is only overwritten
+// when force is set.
+func saveFavorite(name string, f favorite, force bool) error {
+	if err := validateFavoriteName(name); err != nil {
+		return err
+	}
+	return updateFavorites(func(store *favoritesStore) error {
+		if existing, exists := store.Favorites[name]; exists && !force {
+			return fmt.Errorf("favorite %q already exists (%s/%s), use -force to overwrite it", name, existing.Context, existing.Namespace)
+		}
+		store.Favorites[name] = f
+		return nil
+	})
+}
+
+func removeFavorite(name string) error {
+	return updateFavorites(func(store *favoritesStore) error {
+		if _, exists := store.Favorites[name]; !exists {
+			return fmt.Errorf("favorite %q not found", name)
+		}
+		delete(store.Favorites, name)
+		return nil
+	})
+}
+
+func moveFavorite(oldName, newName string, force bool) error {
+	if err := validateFavoriteName(newName); err != nil {
+		return err
+	}
+	return updateFavorites(func(store *favoritesStore) error {
+		f, exists := store.Favorites[oldName]
+		if !exists {
+			return fmt.Errorf("favorite %q not found", oldName)
+		}
+		if oldName == newName {
+			return nil
+		}
+		if existing, exists := store.Favorites[newName]; exists && !force {
+			return fmt.Errorf("favorite %q already exists (%s/%s), use -force to overwrite it", newName, existing.Context, existing.Namespace)
+		}
+		delete(store.Favorites, oldName)
+		store.Favorites[newName] = f
+		return nil
+	})
+}
+
+// updateFavorites applies change to the stored favorites while holding the
+// state lock, so concurrent changes aren't lost.
+func updateFavorites(change func(*favoritesStore) error) error {
+	return withStateLock(func() error {
+		store, err := loadFavorites()
+		if err != nil {
+			return err
+		}
+		if err := change(&store); err != nil {
+			return err
+		}
+		return storeFavorites(store)
+	})
+}
+
+// loadFavorites reads the favorites file. When it doesn't exist yet, favorites
+// stored in the legacy per-key files are migrated to it.
+func loadFavorites() (favoritesStore, error) {
+	data, err := os.ReadFile(path.Join(skDir, favoritesFile))
+	if os.IsNotExist(err) {
+		return migrateLegacyFavorites()
+	}
+	if err != nil {
+		return favoritesStore{}, err
+	}
+
+	store := favoritesStore{}
+	if err := yaml.UnmarshalStrict(data, &store); err != nil {
+		return favoritesStore{}, fmt.Errorf("couldn't read %s: %w", favoritesFile, err)
+	}
+	if store.Version > favoritesVersion {
+		return favoritesStore{}, fmt.Errorf("%s has version %d, this sk only supports up to %d", favoritesFile, store.Version, favoritesVersion)
+	}
+	if store.Favorites == nil {
+		store.Favorites = map[string]favorite{}
+	}
+	return store, nil
+}
+
+func storeFavorites(store favoritesStore) error {
+	store.Version = favoritesVersion
+	data, err := yaml.Marshal(store)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path.Join(skDir, favoritesFile), data)
+}
+
+// migrateLegacyFavorites pairs up the favorite_context_* and
+// favorite_namespace_* files, writes them to the favorites file and removes
+// them. A favorite missing one of its files keeps an empty value for it.
+func migrateLegacyFavorites() (favoritesStore, error) {
+	store := favoritesStore{Version: favoritesVersion, Favorites: map[string]favorite{}}
+
+	files, err := os.ReadDir(skDir)
+	if os.IsNotExist(err) {
+		// Nothing stored yet
+		return store, nil
+	}
+	if err != nil {
+		return store, err
+	}
+
+	legacyFiles := []string{}
+	for _, file := range files {
+		fileName := file.Name()
+		if file.IsDir() {
+			continue
+		}
+
+		var name string
+		var isContext bool
+		if n, ok := strings.CutPrefix(fileName, favoriteContextKeyPrefix); ok {
+			name, isContext = n, true
+		} else if n, ok := strings.CutPrefix(fileName, favoriteNamespaceKeyPrefix); ok {
+			name = n
+		} else {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			return store, err
+		}
+		f, ok := store.Favorites[name]
+		if !ok || info.ModTime().Before(f.CreatedAt) {
+			f.CreatedAt = info.ModTime().UTC().Truncate(time.Second)
+		}
+		if isContext {
+			f.Context = readValue(fileName)
+		} else {
+			f.Namespace = readValue(fileName)
+		}
+		store.Favorites[name] = f
+		legacyFiles = append(legacyFiles, fileName)
+	}
+
+	if len(legacyFiles) == 0 {
+		return store, nil
+	}
+	if err := storeFavorites(store); err != nil {
+		return store, err
+	}
+
+	// Best effort: the favorites file wins from now on anyway.
+	for _, fileName := range legacyFiles {
+		_ = os.Remove(path.Join(skDir, fileName))
+	}
+	return store, nil
+}
+
+func sortedFavoriteNames(favorites map[string]favorite) []string {
+	names := []string{}
+	for name := range favorites {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// parseTags splits a comma separated list of tags, dropping empty ones.
+func parseTags(s string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// validateFavoriteName rejects names that can't be typed as a single argument.
+func validateFavoriteName(name string) error {
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("invalid favorite name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveFavorite_RefusesToOverwriteUnlessForced(t *testing.T) {
+	setSkDir(t)
+
+	require.NoError(t, saveFavorite("prod", favoriteFrom("prod-eu", "payments"), false))
+	err := saveFavorite("prod", favoriteFrom("prod-us", "orders"), false)
+	assert.EqualError(t, err, `favorite "prod" already exists (prod-eu/payments), use -force to overwrite it`)
+
+	f, _ := readFavorite("prod")
+	assert.Equal(t, "prod-eu", f.Context)
+
+	require.NoError(t, saveFavorite("prod", favoriteFrom("prod-us", "orders"), true))
+	f, _ = readFavorite("prod")
+	assert.Equal(t, "prod-us", f.Context)
+	assert.Equal(t, "orders", f.Namespace)
+}
+
+func TestSaveFavorite_RejectsUntypeableNames(t *testing.T) {
+	setSkDir(t)
+
+	for _, name := range []string{"", " ", "a b"} {
+		assert.Error(t, saveFavorite(name, favoriteFrom("ctx", "ns"), false), name)
+	}
+}
+
+func TestSaveFavorite_KeepsMetadata(t *testing.T) {
+	dir := setSkDir(t)
+	f := favoriteFrom("prod-eu", "payments")
+	f.Description = "EU payments"
+	f.Tags = parseTags("prod, eu,,prod")
+
+	require.NoError(t, saveFavorite("pay", f, false))
+
+	got, exists := readFavorite("pay")
+	require.True(t, exists)
+	assert.Equal(t, f, got)
+	assert.Equal(t, []string{"prod", "eu"}, got.Tags)
+
+	data, err := os.ReadFile(filepath.Join(dir, favoritesFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "version: 1")
+}
+
+func TestRemoveFavorite(t *testing.T) {
+	setSkDir(t)
+	require.NoError(t, saveFavorite("dev", favoriteFrom("ctx", "ns"), false))
+	require.NoError(t, saveFavorite("prod", favoriteFrom("ctx", "ns"), false))
+
+	require.NoError(t, removeFavorite("dev"))
+
+	_, exists := readFavorite("dev")
+	assert.False(t, exists)
+	_, exists = readFavorite("prod")
+	assert.True(t, exists)
+	assert.EqualError(t, removeFavorite("dev"), `favorite "dev" not found`)
+}
+
+func TestMoveFavorite(t *testing.T) {
+	setSkDir(t)
+	require.NoError(t, saveFavorite("old", favoriteFrom("ctx", "ns"), false))
+	require.NoError(t, saveFavorite("taken", favoriteFrom("other", "ns"), false))
+
+	assert.Error(t, moveFavorite("old", "taken", false))
+	assert.Error(t, moveFavorite("missing", "new", false))
+
+	require.NoError(t, moveFavorite("old", "new", false))
+	_, exists := readFavorite("old")
+	assert.False(t, exists)
+	f, exists := readFavorite("new")
+	assert.True(t, exists)
+	assert.Equal(t, "ctx", f.Context)
+
+	require.NoError(t, moveFavorite("new", "taken", true))
+	f, _ = readFavorite("taken")
+	assert.Equal(t, "ctx", f.Context)
+	assert.Len(t, readFavorites(), 1)
+}
+
+func TestLoadFavorites_MigratesLegacyFiles(t *testing.T) {
+	dir := setSkDir(t)
+	require.NoError(t, storeValue(favoriteContextKeyPrefix+"dev", "dev-ctx"))
+	require.NoError(t, storeValue(favoriteNamespaceKeyPrefix+"dev", "dev-ns"))
+	require.NoError(t, storeValue(favoriteContextKeyPrefix+"half", "half-ctx"))
+	require.NoError(t, storeValue(previousStateFile, "ctx\nns"))
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, favoriteContextKeyPrefix+"dev"), modTime, modTime))
+
+	favorites := readFavorites()
+
+	assert.Equal(t, "dev-ctx", favorites["dev"].Context)
+	assert.Equal(t, "dev-ns", favorites["dev"].Namespace)
+	assert.Equal(t, modTime, favorites["dev"].CreatedAt)
+	assert.Equal(t, "half-ctx", favorites["half"].Context)
+	assert.Empty(t, favorites["half"].Namespace)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{favoritesFile, previousStateFile, locksDir}, names)
+
+	// Already migrated: the next read comes from the favorites file.
+	assert.Equal(t, favorites, readFavorites())
+}
+
+func TestLoadFavorites_RejectsNewerVersion(t *testing.T) {
+	dir := setSkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, favoritesFile), []byte("version: 2\nfavorites: {}\n"), 0o600))
+
+	_, err := loadFavorites()
+	assert.Error(t, err)
+}
+
+func TestFavoriteNotFoundMessage(t *testing.T) {
+	favorites := map[string]favorite{
+		"prod": favoriteFrom("prod-eu", "payments"),
+		"dev":  favoriteFrom("dev", "default"),
+	}
+
+	assert.Equal(t, "Favorite 'prdo' not found, did you mean 'prod'?", favoriteNotFoundMessage(favorites, "prdo"))
+	assert.Equal(t, "Favorite 'staging' not found, use -l to list favorites", favoriteNotFoundMessage(favorites, "staging"))
+}
+
+func TestFavoriteDescription(t *testing.T) {
+	f := favoriteFrom("prod-eu", "payments")
+	assert.Equal(t, "→  prod-eu/payments", favoriteDescription(f))
+
+	f.Description = "EU payments"
+	assert.Equal(t, "→  prod-eu/payments  EU payments", favoriteDescription(f))
+}
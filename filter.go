@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// contextFilter is set by -filter and limits the contexts offered to the ones
+// matching it.
+var contextFilter string
+
+// isRegexPattern reports whether pattern is a regular expression written as
+// /expression/ rather than a glob.
+func isRegexPattern(pattern string) bool {
+	return len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// validatePattern checks that pattern is a valid glob or /regular expression/.
+func validatePattern(pattern string) error {
+	if isRegexPattern(pattern) {
+		_, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		return err
+	}
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+// matchPattern matches name against a glob, or a regular expression written
+// as /expression/. Invalid patterns match nothing.
+func matchPattern(pattern, name string) bool {
+	if isRegexPattern(pattern) {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		return err == nil && re.MatchString(name)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPattern turns a -filter value into a pattern. A plain word, without
+// glob characters or slashes around it, matches anywhere in the name.
+func filterPattern(filter string) string {
+	if isRegexPattern(filter) || strings.ContainsAny(filter, "*?[") {
+		return filter
+	}
+	return "*" + filter + "*"
+}
+
+// validateFilter checks the -filter value.
+func validateFilter(filter string) error {
+	if err := validatePattern(filterPattern(filter)); err != nil {
+		return fmt.Errorf("invalid -filter %q: %w", filter, err)
+	}
+	return nil
+}
+
+// isContextShown reports whether contextName is offered in prompts and
+// completion: it must match includeContexts when set and -filter when given,
+// and must not match hiddenContexts.
+func isContextShown(contextName string) bool {
+	if matchesAny(skCfg.HiddenContexts, contextName) {
+		return false
+	}
+	if len(skCfg.IncludeContexts) > 0 && !matchesAny(skCfg.IncludeContexts, contextName) {
+		return false
+	}
+	return contextFilter == "" || matchPattern(filterPattern(contextFilter), contextName)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPattern(t *testing.T) {
+	assert.True(t, matchPattern("*prod*", "eu-prod-1"))
+	assert.False(t, matchPattern("prod", "eu-prod-1"))
+	assert.True(t, matchPattern("/^eu-(prod|staging)-[0-9]+$/", "eu-prod-1"))
+	assert.False(t, matchPattern("/^us-/", "eu-prod-1"))
+	assert.False(t, matchPattern("/[/", "eu-prod-1"))
+}
+
+func TestValidatePattern(t *testing.T) {
+	assert.NoError(t, validatePattern("*prod*"))
+	assert.NoError(t, validatePattern("/prod|staging/"))
+	assert.Error(t, validatePattern("[prod"))
+	assert.Error(t, validatePattern("/(prod/"))
+}
+
+func TestFilterPattern(t *testing.T) {
+	assert.Equal(t, "*prod*", filterPattern("prod"))
+	assert.Equal(t, "prod-*", filterPattern("prod-*"))
+	assert.Equal(t, "/^prod/", filterPattern("/^prod/"))
+	assert.EqualError(t, validateFilter("/(/"), "invalid -filter \"/(/\": error parsing regexp: missing closing ): `(`")
+}
+
+func TestGetContextNames_AppliesIncludeExcludeAndFilter(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{IncludeContexts: []string{"eu-*", "/^us-/"}, HiddenContexts: []string{"*-old"}})
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "local",
+		"local", "eu-prod", "eu-staging", "eu-prod-old", "us-prod", "minikube"))
+	rawConfig := loadRawConfig(t)
+
+	assert.Equal(t, []string{"local", "eu-prod", "eu-staging", "us-prod"}, getContextNames(rawConfig))
+
+	contextFilter = "prod"
+	t.Cleanup(func() { contextFilter = "" })
+	assert.Equal(t, []string{"local", "eu-prod", "us-prod"}, getContextNames(rawConfig))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// frecencyWeight is how much a single visit counts towards a frecency score,
+// depending on how long ago it was.
+func frecencyWeight(age time.Duration) float64 {
+	switch {
+	case age < time.Hour:
+		return 8
+	case age < 24*time.Hour:
+		return 4
+	case age < 7*24*time.Hour:
+		return 2
+	case age < 30*24*time.Hour:
+		return 1
+	default:
+		return 0.5
+	}
+}
+
+// frecencyScores adds up the weighted visits in the history per key, with
+// key deciding what an entry counts towards. Entries for which key returns
+// "" are ignored.
+func frecencyScores(key func(historyEntry) string) map[string]float64 {
+	now := time.Now()
+	scores := map[string]float64{}
+	for _, e := range readHistory() {
+		if k := key(e); k != "" {
+			scores[k] += frecencyWeight(now.Sub(e.Time))
+		}
+	}
+	return scores
+}
+
+// rankByFrecency sorts names by score, highest first, and alphabetically when
+// scores are equal.
+func rankByFrecency(names []string, scores map[string]float64) []string {
+	ranked := slices.Clone(names)
+	slices.SortFunc(ranked, func(a, b string) int {
+		if scores[a] != scores[b] {
+			if scores[a] > scores[b] {
+				return -1
+			}
+			return 1
+		}
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+		return 0
+	})
+	return ranked
+}
+
+// orderContexts ranks context names by how often and how recently they were
+// switched to, with the current context on top.
+func orderContexts(names []string, current string) []string {
+	scores := frecencyScores(func(e historyEntry) string { return e.Context })
+	return currentFirst(rankByFrecency(names, scores), current)
+}
+
+// orderNamespaces ranks the namespaces of contextName by how often and how
+// recently they were switched to, with the current namespace on top.
+func orderNamespaces(contextName string, names []string, current string) []string {
+	scores := frecencyScores(func(e historyEntry) string {
+		if e.Context != contextName {
+			return ""
+		}
+		return e.Namespace
+	})
+	return currentFirst(rankByFrecency(names, scores), current)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeHistory replaces the history with entries for the given places, each
+// visited age ago.
+func writeHistory(t *testing.T, visits map[string]time.Duration) {
+	t.Helper()
+	var lines []string
+	for place, age := range visits {
+		ctx, ns, _ := strings.Cut(place, "/")
+		lines = append(lines, time.Now().Add(-age).UTC().Format(time.RFC3339)+"\t"+ctx+"\t"+ns)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(skDir, historyFile), []byte(strings.Join(lines, "\n")+"\n"), 0644))
+}
+
+func TestRankByFrecency_TiesAreAlphabetical(t *testing.T) {
+	scores := map[string]float64{"b": 2, "d": 2, "c": 5}
+	assert.Equal(t, []string{"c", "b", "d", "a", "e"}, rankByFrecency([]string{"e", "d", "c", "b", "a"}, scores))
+}
+
+func TestOrderContexts_RecentOutranksOld(t *testing.T) {
+	setSkDir(t)
+	writeHistory(t, map[string]time.Duration{
+		"old/default":    60 * 24 * time.Hour,
+		"recent/default": time.Minute,
+	})
+
+	assert.Equal(t, []string{"current", "recent", "old", "a", "z"},
+		orderContexts([]string{"z", "old", "a", "recent", "current"}, "current"))
+}
+
+func TestOrderContexts_StableWithoutHistory(t *testing.T) {
+	setSkDir(t)
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "ctx-c", "ctx-d", "ctx-a", "ctx-c", "ctx-b"))
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, []string{"ctx-c", "ctx-a", "ctx-b", "ctx-d"}, getContextNames(loadRawConfig(t)))
+	}
+}
+
+func TestOrderNamespaces_OnlyCountsVisitsInContext(t *testing.T) {
+	setSkDir(t)
+	require.NoError(t, recordSwitch("", "", "ctx-a", "orders"))
+	require.NoError(t, recordSwitch("", "", "ctx-b", "payments"))
+	require.NoError(t, recordSwitch("", "", "ctx-b", "payments"))
+
+	assert.Equal(t, []string{"default", "orders", "payments"},
+		orderNamespaces("ctx-a", []string{"payments", "orders", "default"}, "default"))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	prompt "github.com/c-bata/go-prompt"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Values of groupContextsBy.
+const (
+	groupByServer   = "server"
+	groupByProvider = "provider"
+	groupByRegion   = "region"
+)
+
+// gkeZone matches a GKE zone like "europe-west1-b", as opposed to a region.
+var gkeZone = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+-[a-z]$`)
+
+// contextSuggestions turns context names into prompt suggestions, described
+// by contextPreview. With groupContextsBy set, contexts of the same group are
+// kept together, groups ordered by their first context, and the group is
+// shown in front of the preview.
+func contextSuggestions(rawConfig api.Config, contexts []string) []prompt.Suggest {
+	by := skCfg.GroupContextsBy
+	groups := []string{""}
+	members := map[string][]string{"": contexts}
+	if by != "" {
+		groups, members = []string{}, map[string][]string{}
+		for _, name := range contexts {
+			group := contextGroup(rawConfig, name, by)
+			if _, ok := members[group]; !ok {
+				groups = append(groups, group)
+			}
+			members[group] = append(members[group], name)
+		}
+	}
+
+	suggestions := []prompt.Suggest{}
+	for _, group := range groups {
+		for _, name := range members[group] {
+			details := []string{}
+			// Grouped by server, the group is the host the preview starts with
+			if group != "" && by != groupByServer {
+				details = append(details, group)
+			}
+			if preview := contextPreview(rawConfig, name); preview != "" {
+				details = append(details, preview)
+			}
+			suggestions = append(suggestions, prompt.Suggest{Text: name, Description: strings.Join(details, previewSeparator)})
+		}
+	}
+	return suggestions
+}
+
+// contextGroup derives the group of contextName: the host of its cluster's
+// server, or the cloud provider or region recognized from EKS, GKE and AKS
+// context names and server hosts. It is "" when nothing can be derived.
+func contextGroup(rawConfig api.Config, contextName, by string) string {
+	host := serverHost(rawConfig, contextName)
+	switch by {
+	case groupByServer:
+		return host
+	case groupByProvider:
+		return contextProvider(contextName, host)
+	case groupByRegion:
+		return contextRegion(contextName, host)
+	}
+	return ""
+}
+
+func serverHost(rawConfig api.Config, contextName string) string {
+	ctx := rawConfig.Contexts[contextName]
+	if ctx == nil {
+		return ""
+	}
+	cluster := rawConfig.Clusters[ctx.Cluster]
+	if cluster == nil {
+		return ""
+	}
+	u, err := url.Parse(cluster.Server)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func contextProvider(contextName, host string) string {
+	switch {
+	case strings.HasPrefix(contextName, "arn:aws:eks:"), strings.HasSuffix(host, ".eks.amazonaws.com"):
+		return "aws"
+	case strings.HasPrefix(contextName, "gke_"):
+		return "gcp"
+	case strings.HasSuffix(host, ".azmk8s.io"):
+		return "azure"
+	}
+	return ""
+}
+
+// contextRegion finds the region in EKS context names
+// (arn:aws:eks:<region>:<account>:cluster/<name>) and server hosts
+// (<id>.<x>.<region>.eks.amazonaws.com), GKE context names
+// (gke_<project>_<region or zone>_<name>) and AKS server hosts
+// (<name>.<x>.<region>.azmk8s.io).
+func contextRegion(contextName, host string) string {
+	if rest, ok := strings.CutPrefix(contextName, "arn:aws:eks:"); ok {
+		region, _, _ := strings.Cut(rest, ":")
+		return region
+	}
+	if strings.HasPrefix(contextName, "gke_") {
+		parts := strings.Split(contextName, "_")
+		if len(parts) == 4 {
+			location := parts[2]
+			if gkeZone.MatchString(location) {
+				return location[:len(location)-2]
+			}
+			return location
+		}
+	}
+	labels := strings.Split(host, ".")
+	switch {
+	case strings.HasSuffix(host, ".eks.amazonaws.com") && len(labels) >= 5:
+		return labels[len(labels)-4]
+	case strings.HasSuffix(host, ".azmk8s.io") && len(labels) >= 4:
+		return labels[len(labels)-3]
+	}
+	return ""
+}
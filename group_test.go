@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	prompt "github.com/c-bata/go-prompt"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestContextRegionAndProvider(t *testing.T) {
+	tests := []struct {
+		context, host    string
+		provider, region string
+	}{
+		{"arn:aws:eks:eu-west-1:123456789012:cluster/payments", "abc.gr7.eu-west-1.eks.amazonaws.com", "aws", "eu-west-1"},
+		{"payments-eks", "abc.yl4.us-east-2.eks.amazonaws.com", "aws", "us-east-2"},
+		{"gke_my-project_europe-west1-b_payments", "10.0.0.1", "gcp", "europe-west1"},
+		{"gke_my-project_us-central1_payments", "10.0.0.1", "gcp", "us-central1"},
+		{"payments-aks", "payments-dns-1a2b.hcp.westeurope.azmk8s.io", "azure", "westeurope"},
+		{"kind-local", "127.0.0.1", "", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.provider, contextProvider(tt.context, tt.host), tt.context)
+		assert.Equal(t, tt.region, contextRegion(tt.context, tt.host), tt.context)
+	}
+}
+
+func TestContextSuggestions_GroupsTogether(t *testing.T) {
+	rawConfig := api.Config{
+		Clusters: map[string]*api.Cluster{
+			"a": {Server: "https://a.example.com:6443"},
+			"b": {Server: "https://b.example.com"},
+		},
+		Contexts: map[string]*api.Context{
+			"a-1": {Cluster: "a"},
+			"b-1": {Cluster: "b"},
+			"a-2": {Cluster: "a"},
+		},
+	}
+	contexts := []string{"a-1", "b-1", "a-2"}
+
+	setSkConfig(t, skConfig{})
+	assert.Equal(t, []prompt.Suggest{
+		{Text: "a-1", Description: "a.example.com"},
+		{Text: "b-1", Description: "b.example.com"},
+		{Text: "a-2", Description: "a.example.com"},
+	}, contextSuggestions(rawConfig, contexts))
+
+	setSkConfig(t, skConfig{GroupContextsBy: groupByServer})
+	assert.Equal(t, []prompt.Suggest{
+		{Text: "a-1", Description: "a.example.com"},
+		{Text: "a-2", Description: "a.example.com"},
+		{Text: "b-1", Description: "b.example.com"},
+	}, contextSuggestions(rawConfig, contexts))
+
+	setSkConfig(t, skConfig{GroupContextsBy: groupByProvider})
+	rawConfig.Clusters["b"].Server = "https://b.gr7.eu-west-1.eks.amazonaws.com"
+	assert.Equal(t, []prompt.Suggest{
+		{Text: "a-1", Description: "a.example.com"},
+		{Text: "a-2", Description: "a.example.com"},
+		{Text: "b-1", Description: "aws · b.gr7.eu-west-1.eks.amazonaws.com"},
+	}, contextSuggestions(rawConfig, contexts))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	prompt "github.com/c-bata/go-prompt"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// historyFile is a log of "time\tcontext\tnamespace" lines, oldest first,
+	// one per place switched to. Like previousStateFile it is replaced
+	// atomically, so readers never see a partially written entry.
+	historyFile       = "history"
+	maxHistoryEntries = 500
+)
+
+type historyEntry struct {
+	Time      time.Time
+	Context   string
+	Namespace string
+}
+
+func (e historyEntry) target() string {
+	return fmt.Sprintf("%s/%s", e.Context, e.Namespace)
+}
+
+func (e historyEntry) is(context, namespace string) bool {
+	return e.Context == context && e.Namespace == namespace
+}
+
+// readHistory returns all history entries, oldest first. Malformed lines are
+// skipped.
+func readHistory() []historyEntry {
+	data, err := os.ReadFile(path.Join(skDir, historyFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	checkErr(err)
+
+	entries := []historyEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, historyEntry{Time: t, Context: fields[1], Namespace: fields[2]})
+	}
+	return entries
+}
+
+// recordSwitch appends a switch from one place to another to the history.
+// The place switched from is only added when it isn't already the last
+// entry, e.g. when it was reached through kubectl rather than sk.
+func recordSwitch(fromContext, fromNamespace, toContext, toNamespace string) error {
+	return withStateLock(func() error {
+		entries := readHistory()
+		now := time.Now().UTC().Truncate(time.Second)
+		if fromContext != "" && (len(entries) == 0 || !entries[len(entries)-1].is(fromContext, fromNamespace)) {
+			entries = append(entries, historyEntry{Time: now, Context: fromContext, Namespace: fromNamespace})
+		}
+		entries = append(entries, historyEntry{Time: now, Context: toContext, Namespace: toNamespace})
+		if len(entries) > maxHistoryEntries {
+			entries = entries[len(entries)-maxHistoryEntries:]
+		}
+
+		var buf bytes.Buffer
+		for _, e := range entries {
+			fmt.Fprintf(&buf, "%s\t%s\t%s\n", e.Time.Format(time.RFC3339), e.Context, e.Namespace)
+		}
+		return writeFileAtomic(path.Join(skDir, historyFile), buf.Bytes())
+	})
+}
+
+// historySteps returns the places in the history, newest first, leaving out
+// the current one and repeats of the same place in a row. Step N back is at
+// index N-1.
+func historySteps(currentContext, currentNamespace string) []historyEntry {
+	entries := readHistory()
+	steps := []historyEntry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if len(steps) == 0 && e.is(currentContext, currentNamespace) {
+			continue
+		}
+		if len(steps) > 0 && steps[len(steps)-1].is(e.Context, e.Namespace) {
+			continue
+		}
+		steps = append(steps, e)
+	}
+	return steps
+}
+
+// historyStep returns the place n steps back in the history.
+func historyStep(rawConfig api.Config, n int) (historyEntry, error) {
+	steps := historySteps(currentContextAndNamespace(rawConfig))
+	if n < 1 || n > len(steps) {
+		return historyEntry{}, fmt.Errorf("can't go %d steps back, the history has %d", n, len(steps))
+	}
+	return steps[n-1], nil
+}
+
+// parseSteps parses the N of "sk -p N".
+func parseSteps(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("'%s' is not a number of steps back", arg)
+	}
+	return n, nil
+}
+
+// printHistory lists the history as used by "sk -p N", newest first.
+func printHistory(rawConfig api.Config) {
+	for i, e := range historySteps(currentContextAndNamespace(rawConfig)) {
+		fmt.Printf("%3d  %s  %s\n", i+1, e.Time.Local().Format("2006-01-02 15:04"), e.target())
+	}
+}
+
+// selectHistory shows a prompt with every place in the history once, most
+// recently visited first, and returns the chosen one.
+func selectHistory(rawConfig api.Config) historyEntry {
+	seen := map[string]historyEntry{}
+	suggestions := []prompt.Suggest{}
+	for _, e := range historySteps(currentContextAndNamespace(rawConfig)) {
+		if _, ok := seen[e.target()]; ok {
+			continue
+		}
+		seen[e.target()] = e
+		suggestions = append(suggestions, prompt.Suggest{Text: e.target(), Description: timeAgo(e.Time)})
+	}
+	if len(suggestions) == 0 {
+		fail("No history yet")
+	}
+
+	selection := showSuggestPrompt(suggestions)
+	e, ok := seen[selection]
+	if !ok {
+		fail(fmt.Sprintf("'%s' is not a valid history selection", selection))
+	}
+	return e
+}
+
+// timeAgo formats how long ago t was, e.g. "5m ago".
+func timeAgo(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func targets(entries []historyEntry) []string {
+	out := []string{}
+	for _, e := range entries {
+		out = append(out, e.target())
+	}
+	return out
+}
+
+func TestRecordSwitch_AddsOriginOnlyWhenNotLastEntry(t *testing.T) {
+	setSkDir(t)
+
+	require.NoError(t, recordSwitch("ctx-a", "ns-a", "ctx-b", "ns-b"))
+	require.NoError(t, recordSwitch("ctx-b", "ns-b", "ctx-c", "ns-c"))
+	// Changed outside of sk in between
+	require.NoError(t, recordSwitch("ctx-d", "ns-d", "ctx-a", "ns-a"))
+
+	assert.Equal(t, []string{"ctx-a/ns-a", "ctx-b/ns-b", "ctx-c/ns-c", "ctx-d/ns-d", "ctx-a/ns-a"}, targets(readHistory()))
+}
+
+func TestRecordSwitch_KeepsNewestEntries(t *testing.T) {
+	setSkDir(t)
+
+	for i := 0; i < maxHistoryEntries+10; i++ {
+		require.NoError(t, recordSwitch("", "", fmt.Sprintf("ctx-%d", i), "default"))
+	}
+
+	entries := readHistory()
+	require.Len(t, entries, maxHistoryEntries)
+	assert.Equal(t, "ctx-10", entries[0].Context)
+	assert.Equal(t, fmt.Sprintf("ctx-%d", maxHistoryEntries+9), entries[len(entries)-1].Context)
+}
+
+func TestReadHistory_SkipsMalformedLines(t *testing.T) {
+	dir := setSkDir(t)
+	content := "garbage\n2026-01-02T03:04:05Z\tctx-a\tns-a\nnot-a-time\tctx-b\tns-b\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, historyFile), []byte(content), 0644))
+
+	assert.Equal(t, []string{"ctx-a/ns-a"}, targets(readHistory()))
+}
+
+func TestHistorySteps_SkipsCurrentAndRepeats(t *testing.T) {
+	setSkDir(t)
+	require.NoError(t, recordSwitch("ctx-a", "ns-a", "ctx-b", "ns-b"))
+	require.NoError(t, recordSwitch("ctx-b", "ns-b", "ctx-b", "ns-b"))
+	require.NoError(t, recordSwitch("ctx-b", "ns-b", "ctx-c", "ns-c"))
+
+	assert.Equal(t, []string{"ctx-b/ns-b", "ctx-a/ns-a"}, targets(historySteps("ctx-c", "ns-c")))
+	assert.Equal(t, []string{"ctx-c/ns-c", "ctx-b/ns-b", "ctx-a/ns-a"}, targets(historySteps("ctx-x", "ns-x")))
+}
+
+func TestHistoryStep_GoesBackNSteps(t *testing.T) {
+	setSkDir(t)
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "ctx-c", "ctx-a", "ctx-b", "ctx-c"))
+	require.NoError(t, recordSwitch("ctx-a", "default", "ctx-b", "default"))
+	require.NoError(t, recordSwitch("ctx-b", "default", "ctx-c", "default"))
+	rawConfig := loadRawConfig(t)
+
+	e, err := historyStep(rawConfig, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "ctx-a", e.Context)
+
+	_, err = historyStep(rawConfig, 3)
+	assert.EqualError(t, err, "can't go 3 steps back, the history has 2")
+}
+
+func TestParseSteps(t *testing.T) {
+	n, err := parseSteps("3")
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	_, err = parseSteps("0")
+	assert.Error(t, err)
+	_, err = parseSteps("prod")
+	assert.Error(t, err)
+}
@@ -3,10 +3,8 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -115,8 +113,8 @@ func TestMain(m *testing.M) {
 // setupTest prepares an isolated test environment:
 //   - copies baseKubeConfig into a fresh temp file so each test starts from a known state,
 //   - overrides the package-level kubeConfigPath and skDir globals,
-//   - sets the KUBECONFIG env var so that setConfig() (which uses NewDefaultPathOptions)
-//     writes to the same temp file.
+//   - sets the KUBECONFIG env var so that anything resolving the kubeconfig from the
+//     environment sees the same temp file.
 //
 // All changes are automatically reverted via t.Cleanup.
 func setupTest(t *testing.T) string {
@@ -146,22 +144,6 @@ func setupTest(t *testing.T) string {
 	return kubeconfigFile
 }
 
-// captureStdout captures whatever fn writes to os.Stdout and returns it as a string.
-func captureStdout(t *testing.T, fn func()) string {
-	t.Helper()
-	r, w, err := os.Pipe()
-	require.NoError(t, err)
-	origStdout := os.Stdout
-	os.Stdout = w
-	fn()
-	w.Close()
-	os.Stdout = origStdout
-	var buf bytes.Buffer
-	_, err = io.Copy(&buf, r)
-	require.NoError(t, err)
-	return buf.String()
-}
-
 // ── Context tests ─────────────────────────────────────────────────────────────
 
 func TestGetContextNames_ListsAllContexts(t *testing.T) {
@@ -365,21 +347,24 @@ func TestFavorite_StoreAndLoad(t *testing.T) {
 	setupTest(t)
 
 	favName := "staging"
-	require.NoError(t, storeValue(favoriteContextKeyPrefix+favName, ctxBeta))
-	require.NoError(t, storeValue(favoriteNamespaceKeyPrefix+favName, testNamespace2))
+	require.NoError(t, saveFavorite(favName, favoriteFrom(ctxBeta, testNamespace2), false))
 
-	assert.Equal(t, ctxBeta, readValue(favoriteContextKeyPrefix+favName))
-	assert.Equal(t, testNamespace2, readValue(favoriteNamespaceKeyPrefix+favName))
+	fav, exists := readFavorite(favName)
+	require.True(t, exists)
+	assert.Equal(t, ctxBeta, fav.Context)
+	assert.Equal(t, testNamespace2, fav.Namespace)
 }
 
 func TestFavorite_OverwriteExisting(t *testing.T) {
 	setupTest(t)
 
 	favName := "env"
-	require.NoError(t, storeValue(favoriteContextKeyPrefix+favName, ctxAlpha))
-	require.NoError(t, storeValue(favoriteContextKeyPrefix+favName, ctxGamma))
+	require.NoError(t, saveFavorite(favName, favoriteFrom(ctxAlpha, "default"), false))
+	require.Error(t, saveFavorite(favName, favoriteFrom(ctxGamma, "default"), false))
+	require.NoError(t, saveFavorite(favName, favoriteFrom(ctxGamma, "default"), true))
 
-	assert.Equal(t, ctxGamma, readValue(favoriteContextKeyPrefix+favName))
+	fav, _ := readFavorite(favName)
+	assert.Equal(t, ctxGamma, fav.Context)
 }
 
 func TestFavorite_ApplyFavorite(t *testing.T) {
@@ -387,19 +372,16 @@ func TestFavorite_ApplyFavorite(t *testing.T) {
 	setupTest(t)
 
 	favName := "prod"
-	require.NoError(t, storeValue(favoriteContextKeyPrefix+favName, ctxGamma))
-	require.NoError(t, storeValue(favoriteNamespaceKeyPrefix+favName, testNamespace2))
+	require.NoError(t, saveFavorite(favName, favoriteFrom(ctxGamma, testNamespace2), false))
 
-	favCtx := readValue(favoriteContextKeyPrefix + favName)
-	favNs := readValue(favoriteNamespaceKeyPrefix + favName)
-	require.Equal(t, ctxGamma, favCtx)
-	require.Equal(t, testNamespace2, favNs)
+	fav, exists := readFavorite(favName)
+	require.True(t, exists)
+	require.Equal(t, ctxGamma, fav.Context)
+	require.Equal(t, testNamespace2, fav.Namespace)
 
 	cfg, err := loadConfig().RawConfig()
 	require.NoError(t, err)
-	cfg.CurrentContext = favCtx
-	cfg.Contexts[favCtx].Namespace = favNs
-	setConfig(cfg)
+	require.NoError(t, applyFavorite(cfg, fav.Context, fav.Namespace))
 
 	updated, err := loadConfig().RawConfig()
 	require.NoError(t, err)
@@ -413,13 +395,13 @@ func TestFavorite_LoadFailsWhenFavoriteContextMissingFromKubeconfig(t *testing.T
 	// Store a favorite that points to a context not present in kubeconfig so the
 	// shared favorite-apply helper exercises the explicit nil-check guard.
 	favName := "missing"
-	require.NoError(t, storeValue(favoriteContextKeyPrefix+favName, "no-such-context"))
-	require.NoError(t, storeValue(favoriteNamespaceKeyPrefix+favName, "default"))
+	require.NoError(t, saveFavorite(favName, favoriteFrom("no-such-context", "default"), false))
 
 	cfg, err := loadConfig().RawConfig()
 	require.NoError(t, err)
 
-	err = applyFavorite(cfg, readValue(favoriteContextKeyPrefix+favName), readValue(favoriteNamespaceKeyPrefix+favName))
+	fav, _ := readFavorite(favName)
+	err = applyFavorite(cfg, fav.Context, fav.Namespace)
 	require.Error(t, err)
 	assert.EqualError(t, err, "context \"no-such-context\" not found in kubeconfig")
 }
@@ -427,15 +409,23 @@ func TestFavorite_LoadFailsWhenFavoriteContextMissingFromKubeconfig(t *testing.T
 func TestListFavorites_PrintsAllStoredFavorites(t *testing.T) {
 	setupTest(t)
 
+	require.NoError(t, saveFavorite("prod", favoriteFrom(ctxBeta, testNamespace1), false))
+	require.NoError(t, saveFavorite("dev", favoriteFrom(ctxAlpha, "default"), false))
+
+	output := captureStdout(t, printFavorites)
+
+	assert.Equal(t, fmt.Sprintf("dev: %s/default\nprod: %s/%s\n", ctxAlpha, ctxBeta, testNamespace1), output)
+}
+
+func TestListFavorites_MigratesLegacyFavorites(t *testing.T) {
+	setupTest(t)
+
 	require.NoError(t, storeValue(favoriteContextKeyPrefix+"dev", ctxAlpha))
 	require.NoError(t, storeValue(favoriteNamespaceKeyPrefix+"dev", "default"))
-	require.NoError(t, storeValue(favoriteContextKeyPrefix+"prod", ctxBeta))
-	require.NoError(t, storeValue(favoriteNamespaceKeyPrefix+"prod", testNamespace1))
 
 	output := captureStdout(t, printFavorites)
 
-	assert.Contains(t, output, "dev")
-	assert.Contains(t, output, "prod")
+	assert.Equal(t, fmt.Sprintf("dev: %s/default\n", ctxAlpha), output)
 }
 
 func TestListFavorites_EmptyWhenNoneDefined(t *testing.T) {
@@ -623,8 +613,7 @@ func TestFullWorkflow(t *testing.T) {
 	assert.Contains(t, output, testNamespace1)
 
 	// 4. Store as favorite "myenv".
-	require.NoError(t, storeValue(favoriteContextKeyPrefix+"myenv", ctxBeta))
-	require.NoError(t, storeValue(favoriteNamespaceKeyPrefix+"myenv", testNamespace1))
+	require.NoError(t, saveFavorite("myenv", favoriteFrom(ctxBeta, testNamespace1), false))
 
 	// 5. Switch away to ctxGamma and store ctxBeta as "previous".
 	require.NoError(t, storePreviousState(ctxBeta, testNamespace1))
@@ -648,13 +637,10 @@ func TestFullWorkflow(t *testing.T) {
 	assert.Equal(t, testNamespace1, chk.Contexts[ctxBeta].Namespace)
 
 	// 6. Apply favorite (-f semantics).
-	favCtx := readValue(favoriteContextKeyPrefix + "myenv")
-	favNs := readValue(favoriteNamespaceKeyPrefix + "myenv")
+	fav, _ := readFavorite("myenv")
 	cfg7, err := loadConfig().RawConfig()
 	require.NoError(t, err)
-	cfg7.CurrentContext = favCtx
-	cfg7.Contexts[favCtx].Namespace = favNs
-	setConfig(cfg7)
+	require.NoError(t, applyFavorite(cfg7, fav.Context, fav.Namespace))
 
 	final, err := loadConfig().RawConfig()
 	require.NoError(t, err)
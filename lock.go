@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// locksDir holds the advisory lock files sk uses to keep concurrent runs from
+// interleaving their read-modify-write cycles.
+const locksDir = "locks"
+
+// lockTimeout is how long to wait for another run of sk to release a lock.
+var lockTimeout = 10 * time.Second
+
+func init() {
+	// sk holds the "<file>.lock" locks client-go's ModifyConfig would take
+	// for the whole update, see withKubeConfigFilesLock
+	clientcmd.UseModifyConfigLock = false
+}
+
+// acquireLock takes the advisory lock called name, waiting up to lockTimeout
+// for it. The returned func releases it.
+func acquireLock(name string) (func(), error) {
+	if err := os.MkdirAll(path.Join(skDir, locksDir), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path.Join(skDir, locksDir, name+".lock"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if locked {
+			return func() {
+				_ = unlockFile(f)
+				f.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for %s to be unlocked, is another sk still running?", lockTimeout, f.Name())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// withKubeConfigLock runs fn holding a lock for every file in the kubeconfig
+// path.
+func withKubeConfigLock(fn func() error) error {
+	return withKubeConfigFilesLock(splitKubeConfigPath(kubeConfigPath), fn)
+}
+
+// withKubeConfigFilesLock runs fn holding a lock for every one of the
+// kubeconfig files. Files are locked in a fixed order, so runs with
+// overlapping kubeconfig paths can't deadlock.
+func withKubeConfigFilesLock(paths []string, fn func() error) error {
+	names := []string{}
+	for _, p := range paths {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		names = append(names, "kubeconfig-"+checksum([]byte(p))[:16])
+	}
+	slices.Sort(names)
+
+	for _, name := range slices.Compact(names) {
+		release, err := acquireLock(name)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	files := slices.Clone(paths)
+	slices.Sort(files)
+	for _, p := range slices.Compact(files) {
+		release, err := lockKubeConfigFile(p)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	return fn()
+}
+
+// lockKubeConfigFile takes the "<file>.lock" lock that kubectl and other
+// tools built on client-go take while changing a kubeconfig file, waiting up
+// to lockTimeout for it. Unlike sk's own locks it's a file that has to be
+// removed, so nothing may exit the process while it's held. When the lock
+// file can't be created, e.g. in a read-only directory, no other tool can
+// change the file either and no lock is taken.
+func lockKubeConfigFile(p string) (func(), error) {
+	lockPath := p + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return func() {}, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for %s to be removed, is another tool changing the kubeconfig? If not, remove it", lockTimeout, lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// withStateLock runs fn holding the lock for sk's own state files. It must
+// not be nested, and is always taken after the kubeconfig lock.
+func withStateLock(fn func() error) error {
+	release, err := acquireLock("state")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
+
+// updateKubeConfig applies change to a freshly loaded kubeconfig and writes
+// the result, all while holding the kubeconfig lock. Changes made by others
+// since the kubeconfig was loaded for a prompt are kept that way.
+func updateKubeConfig(change func(*api.Config) error) error {
+	return withKubeConfigLock(func() error {
+		rawConfig, err := loadConfig().RawConfig()
+		if err != nil {
+			return err
+		}
+		if err := change(&rawConfig); err != nil {
+			return err
+		}
+		return storeConfig(rawConfig)
+	})
+}
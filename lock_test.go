@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const parallelSwitches = 20
+
+func TestAcquireLock_TimesOut(t *testing.T) {
+	setSkDir(t)
+	orig := lockTimeout
+	lockTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { lockTimeout = orig })
+
+	release, err := acquireLock("test")
+	require.NoError(t, err)
+
+	_, err = acquireLock("test")
+	assert.ErrorContains(t, err, "timed out after 50ms waiting for")
+
+	release()
+	release, err = acquireLock("test")
+	require.NoError(t, err)
+	release()
+}
+
+func TestUpdateKubeConfig_HonorsKubectlLock(t *testing.T) {
+	setSkDir(t)
+	orig := lockTimeout
+	lockTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { lockTimeout = orig })
+	cfgPath := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, cfgPath)
+
+	// Another tool is changing the file
+	require.NoError(t, os.WriteFile(cfgPath+".lock", nil, 0o600))
+	err := applyContextChange(loadRawConfig(t), "ctx-b")
+	assert.ErrorContains(t, err, "waiting for "+cfgPath+".lock to be removed")
+	assert.Equal(t, "ctx-a", loadRawConfig(t).CurrentContext)
+	assert.FileExists(t, cfgPath+".lock", "someone else's lock is left alone")
+
+	require.NoError(t, os.Remove(cfgPath+".lock"))
+	require.NoError(t, applyContextChange(loadRawConfig(t), "ctx-b"))
+	assert.Equal(t, "ctx-b", loadRawConfig(t).CurrentContext)
+	assert.NoFileExists(t, cfgPath+".lock")
+}
+
+func TestUpdateKubeConfig_ParallelSwitchesLoseNothing(t *testing.T) {
+	setSkDir(t)
+	contexts := []string{}
+	for i := 0; i < parallelSwitches; i++ {
+		contexts = append(contexts, fmt.Sprintf("ctx-%d", i))
+	}
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "ctx-0", contexts...))
+	// Every switch starts from the same, soon stale, config
+	stale := loadRawConfig(t)
+
+	var wg sync.WaitGroup
+	for _, name := range contexts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for round := range 5 {
+				assert.NoError(t, applyNamespaceChange(*stale.DeepCopy(), name, fmt.Sprintf("%s-%d", name, round)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	cfg := loadRawConfig(t)
+	for _, name := range contexts {
+		assert.Equal(t, name+"-4", cfg.Contexts[name].Namespace)
+	}
+	assert.Len(t, readJournal(), maxJournalEntries)
+}
+
+func TestStateLock_ParallelUpdatesLoseNothing(t *testing.T) {
+	setSkDir(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallelSwitches; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("fav-%d", i)
+			assert.NoError(t, saveFavorite(name, favoriteFrom(name, "default"), false))
+			assert.NoError(t, recordSwitch("", "", name, "default"))
+			assert.NoError(t, rememberNamespace("ctx", name))
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, readFavorites(), parallelSwitches)
+	assert.Len(t, readHistory(), parallelSwitches)
+	assert.Len(t, readKnownNamespaces()["ctx"], min(parallelSwitches, maxKnownNamespaces))
+}
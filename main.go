@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 
 	"golang.org/x/term"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,7 +34,8 @@ var version = "dev"
 const (
 	// previousStateFile holds "context\nnamespace" and is written atomically
 	// (temp-file + rename) so a concurrent sk -p never sees a torn state.
-	previousStateFile          = "previous_state"
+	previousStateFile = "previous_state"
+	// Legacy per-key favorite files, migrated to favoritesFile on first read.
 	favoriteContextKeyPrefix   = "favorite_context_"
 	favoriteNamespaceKeyPrefix = "favorite_namespace_"
 )
@@ -44,6 +46,18 @@ func main() {
 	saveTermState()
 	defer restoreTermState()
 
+	// Handled before the config is loaded, so it can report what's wrong with it
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		checkErr(runConfigCommand(os.Args[2:]))
+		return
+	}
+
+	// User configuration
+	var err error
+	skCfg, err = readConfig()
+	checkErr(err)
+	applyConfig(skCfg)
+
 	// Create and check config dir
 	checkErr(createSkDir())
 
@@ -55,20 +69,78 @@ func main() {
 	var printCurrent bool
 	var listFavorites bool
 	var favorite string
+	var forceFavorite bool
+	var favoriteDescription string
+	var favoriteTags string
+	var pickHistory bool
+	var outputFormat string
 
 	flag.BoolVar(&printVersion, "v", false, "Print the current version")
-	flag.BoolVar(&switchPrevious, "p", false, "Use to switch to the previously used context and namespace. Has no effect if state can't be retrieved.")
+	flag.BoolVar(&switchPrevious, "p", false, "Use to switch to the previously used context and namespace. Has no effect if state can't be retrieved. With a number N, go N steps back in the history")
 	flag.BoolVar(&nameSpaceMode, "n", false, "Select namespace from the ones available for the selected context")
 	flag.BoolVar(&nameSpaceOnlyMode, "N", false, "Only select namespace from the ones available for the selected context")
 	flag.BoolVar(&printCurrent, "c", false, "Print the currently selected context and namespace")
+	flag.BoolVar(&pickHistory, "H", false, "Select a previously used context and namespace from the history")
 	flag.BoolVar(&listFavorites, "l", false, "List all stored favorites")
 	flag.StringVar(&favorite, "f", "", "Select a favorite context")
 	flag.StringVar(&favorite, "F", "", "Store current context and namespace as favorite")
-	flag.Parse()
+	flag.StringVar(&favorite, "d", "", "Delete a favorite")
+	flag.StringVar(&favorite, "r", "", "Rename a favorite: -r <old> <new>")
+	flag.StringVar(&outputFormat, "o", "", "Output format for -c and -l: json, yaml or tsv")
+	flag.StringVar(&contextFilter, "filter", "", "Only offer contexts matching this word, glob or /regular expression/")
+	flag.BoolVar(&forceFavorite, "force", false, "Overwrite an existing favorite with -F or -r")
+	flag.StringVar(&favoriteDescription, "desc", "", "Description to store with -F")
+	flag.StringVar(&favoriteTags, "tags", "", "Comma separated tags to store with -F")
+	flag.BoolVar(&refreshNamespaces, "refresh", false, "Load namespaces from the cluster instead of the cache")
+	flag.DurationVar(&requestTimeout, "timeout", requestTimeout, "Timeout for requests to the cluster, e.g. 5s")
+	flag.DurationVar(&revertAfter, "for", 0, "Switch back to the previous context and namespace after this long, e.g. 15m")
+	flag.BoolVar(&assumeYes, "y", false, "Switch to protected contexts without asking for confirmation")
+	flag.BoolVar(&sessionRequested, "s", sessionRequested, "Only switch in the current shell session. Prints an export KUBECONFIG line to eval. Default when $SK_SESSION is set")
+
+	// Subcommands, checked before flags are parsed. Flags are defined first so
+	// completion can tell which of them take a value.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "init":
+			checkErr(printShellInit(os.Args[2:]))
+			return
+		case "__complete":
+			printCompletions(os.Args[2:])
+			return
+		case "__prompt":
+			printPrompt()
+			return
+		case "exec":
+			os.Exit(runExec(os.Args[2:]))
+		case "shell":
+			os.Exit(runShell(os.Args[2:]))
+		case "__revert":
+			checkErr(runRevertHelper())
+			return
+		case "undo":
+			checkErr(runUndo(os.Args[2:]))
+			return
+		case "history":
+			rawConfig, err := loadConfig().RawConfig()
+			checkErr(err)
+			printHistory(rawConfig)
+			return
+		}
+	}
+
+	// flag.CommandLine uses ExitOnError, so this never returns an error.
+	_ = flag.CommandLine.Parse(allowBareFlags(os.Args[1:], "f"))
+	args := parseInterspersedArgs()
 
 	// Allow bare "-" as a shorthand for -p (switch to previous context/namespace).
-	if slices.Contains(flag.Args(), "-") {
+	if slices.Contains(args, "-") {
 		switchPrevious = true
+		args = slices.DeleteFunc(args, func(arg string) bool { return arg == "-" })
+	}
+
+	// Anything else is "sk <context> [namespace]", or "sk -N <namespace>".
+	if len(args) > 2 || (nameSpaceOnlyMode && len(args) > 1) {
+		fail(fmt.Sprintf("Too many arguments: %s", strings.Join(args, " ")))
 	}
 
 	if printVersion {
@@ -76,10 +148,38 @@ func main() {
 		return
 	}
 
+	// A switch away from a protected context may have become due
+	checkErr(checkRevert())
+
+	if contextFilter != "" {
+		checkErr(validateFilter(contextFilter))
+	}
+	if outputFormat != "" {
+		if !printCurrent && !listFavorites {
+			fail("-o can only be used with -c or -l")
+		}
+		checkErr(validateOutputFormat(outputFormat))
+	}
+
 	loadFavorite := flagPassed("f")
 	storeFavorite := flagPassed("F")
-	if loadFavorite && storeFavorite {
-		fail("Can't use -f and -F at the same time")
+	deleteFavorite := flagPassed("d")
+	renameFavorite := flagPassed("r")
+	if countTrue(loadFavorite, storeFavorite, deleteFavorite, renameFavorite) > 1 {
+		fail("Can't use more than one of -f, -F, -d and -r at the same time")
+	}
+
+	// Managing favorites doesn't need the kubeconfig
+	if deleteFavorite {
+		checkErr(removeFavorite(favorite))
+		return
+	}
+	if renameFavorite {
+		if len(args) != 1 {
+			fail("Usage: sk -r <old> <new>")
+		}
+		checkErr(moveFavorite(favorite, args[0], forceFavorite))
+		return
 	}
 
 	// Load kube config
@@ -89,7 +189,11 @@ func main() {
 
 	// Print current context and namespace
 	if printCurrent {
-		printCurrentContextAndNamespace(rawConfig)
+		if outputFormat != "" {
+			checkErr(printCurrentState(rawConfig, outputFormat))
+		} else {
+			printCurrentContextAndNamespace(rawConfig)
+		}
 		return
 	}
 
@@ -103,33 +207,70 @@ func main() {
 	}
 
 	if loadFavorite {
-		favoriteContext := readValue(fmt.Sprintf("%s%s", favoriteContextKeyPrefix, favorite))
-		favoriteNamespace := readValue(fmt.Sprintf("%s%s", favoriteNamespaceKeyPrefix, favorite))
-		fmt.Println(favoriteContext)
-		fmt.Println(favoriteNamespace)
-		if favoriteContext != "" && favoriteNamespace != "" {
-			checkErr(applyFavorite(rawConfig, favoriteContext, favoriteNamespace))
+		favorites := readFavorites()
+		if favorite == "" {
+			favorite = selectFavorite(favorites)
+		}
+		f, ok := favorites[favorite]
+		if !ok {
+			fail(favoriteNotFoundMessage(favorites, favorite))
+		}
+		if f.Context == "" {
+			fail(fmt.Sprintf("Favorite '%s' has no context", favorite))
 		}
+		checkErr(applyFavorite(rawConfig, f.Context, f.Namespace))
 	} else if storeFavorite {
-		checkErr(storeValue(fmt.Sprintf("%s%s", favoriteContextKeyPrefix, favorite), currentContext))
-		checkErr(storeValue(fmt.Sprintf("%s%s", favoriteNamespaceKeyPrefix, favorite), currentNamespace))
+		f := favoriteFrom(currentContext, currentNamespace)
+		f.Description = favoriteDescription
+		f.Tags = parseTags(favoriteTags)
+		checkErr(saveFavorite(favorite, f, forceFavorite))
+	} else if switchPrevious && len(args) > 0 {
+		if len(args) > 1 {
+			fail("Usage: sk -p <steps>")
+		}
+		steps, err := parseSteps(args[0])
+		checkErr(err)
+		e, err := historyStep(rawConfig, steps)
+		checkErr(err)
+		checkErr(applyFavorite(rawConfig, e.Context, e.Namespace))
+	} else if pickHistory {
+		e := selectHistory(rawConfig)
+		checkErr(applyFavorite(rawConfig, e.Context, e.Namespace))
 	} else if switchPrevious {
 		previousContext, previousNamespace := readPreviousState()
 		if previousContext != "" {
-			rawConfig.CurrentContext = previousContext
-			rawConfig.Contexts[previousContext].Namespace = previousNamespace
-			setConfig(rawConfig)
+			checkErr(applyFavorite(rawConfig, previousContext, previousNamespace))
 		}
 	} else if listFavorites {
-		printFavorites()
+		if outputFormat != "" {
+			checkErr(printFavoritesOutput(outputFormat))
+		} else {
+			printFavorites()
+		}
 	} else {
 		// Context
-		if !nameSpaceOnlyMode {
-			rawConfig = selectContext(rawConfig)
+		if !nameSpaceOnlyMode && len(args) > 1 {
+			// Both are resolved first, so they're switched to in one change
+			contextName, err := resolveContext(rawConfig, args[0])
+			checkErr(err)
+			namespaceName, err := resolveNamespace(rawConfig, contextName, args[1])
+			checkErr(err)
+			checkErr(applyFavorite(rawConfig, contextName, namespaceName))
+			args = nil
+		} else if !nameSpaceOnlyMode {
+			if len(args) > 0 {
+				rawConfig = switchContext(rawConfig, args[0])
+				args = args[1:]
+			} else {
+				rawConfig = selectContext(rawConfig)
+				nameSpaceMode = nameSpaceMode || skCfg.NamespacePrompt
+			}
 		}
 
 		// Namespace
-		if nameSpaceMode || nameSpaceOnlyMode {
+		if len(args) > 0 {
+			switchNamespace(rawConfig, args[0])
+		} else if nameSpaceMode || nameSpaceOnlyMode {
 			selectNamespace(rawConfig)
 		}
 	}
@@ -147,54 +288,64 @@ func main() {
 		}
 		if newContext != currentContext || newNamespace != currentNamespace {
 			checkErr(storePreviousState(currentContext, currentNamespace))
+			checkErr(rememberNamespace(newContext, newNamespace))
+			checkErr(recordSwitch(currentContext, currentNamespace, newContext, newNamespace))
+			revert, err := updateRevert(currentContext, currentNamespace, newContext, newNamespace)
+			checkErr(err)
+			if revert != nil {
+				checkErr(startRevertHelper())
+				fmt.Fprintf(os.Stderr, "Switching back to %s/%s at %s\n", revert.Context, revert.Namespace, revert.At.Local().Format("15:04"))
+			}
 		}
 	}
-}
-
-func printFavorites() {
-	files, err := os.ReadDir(skDir)
-	if err != nil {
-		fail("Couldn't read sk dir")
-	}
 
-	type favorite struct {
-		context   string
-		namespace string
+	// A new session or overlay only takes effect once the calling shell
+	// picks up the new $KUBECONFIG.
+	if sessionStarted || overlayStarted {
+		printSessionExport()
 	}
+}
 
-	favorites := map[string]favorite{}
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+// allowBareFlags lets the named string flags be passed without a value, as in
+// a bare "sk -f", by turning them into "-f=" when no value follows.
+func allowBareFlags(args []string, names ...string) []string {
+	normalized := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(normalized, args[i:]...)
 		}
-
-		fileName := file.Name()
-		if strings.HasPrefix(fileName, favoriteContextKeyPrefix) {
-			favoriteName := strings.TrimPrefix(fileName, favoriteContextKeyPrefix)
-			c := readValue(fileName)
-			f, ok := favorites[favoriteName]
-			if !ok {
-				favorites[favoriteName] = favorite{context: c, namespace: f.namespace}
-			} else {
-				favorites[favoriteName] = favorite{context: "", namespace: ""}
-			}
+		name := strings.TrimLeft(arg, "-")
+		hasValue := i+1 < len(args) && !strings.HasPrefix(args[i+1], "-")
+		if strings.HasPrefix(arg, "-") && slices.Contains(names, name) && !hasValue {
+			arg += "="
 		}
+		normalized = append(normalized, arg)
+	}
+	return normalized
+}
 
-		if strings.HasPrefix(fileName, favoriteNamespaceKeyPrefix) {
-			favoriteName := strings.TrimPrefix(fileName, favoriteNamespaceKeyPrefix)
-			n := readValue(fileName)
-			f, ok := favorites[favoriteName]
-			if ok {
-				favorites[favoriteName] = favorite{context: f.context, namespace: n}
-			} else {
-				favorites[favoriteName] = favorite{context: "", namespace: ""}
-			}
-		}
+// parseInterspersedArgs continues flag parsing past positional arguments, so
+// "sk prod -n" works the same as "sk -n prod". Returns the positional ones.
+func parseInterspersedArgs() []string {
+	args := []string{}
+	rest := flag.Args()
+	for len(rest) > 0 {
+		args = append(args, rest[0])
+		// flag.CommandLine uses ExitOnError, so this never returns an error.
+		_ = flag.CommandLine.Parse(rest[1:])
+		rest = flag.Args()
 	}
+	return args
+}
 
-	for k, v := range favorites {
-		fmt.Printf("%s: %s/%s\n", k, v.context, v.namespace)
+func countTrue(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
 	}
+	return n
 }
 
 func flagPassed(name string) bool {
@@ -221,32 +372,40 @@ func restoreTermState() {
 	}
 }
 
+// getContextNames returns the context names with the current one on top,
+// followed by the rest in frecency order. Contexts that aren't shown because
+// of the config or -filter are left out.
 func getContextNames(rawConfig api.Config) []string {
 	contexts := []string{}
 	for context := range rawConfig.Contexts {
-		// Current value on top
-		if context == rawConfig.CurrentContext {
-			contexts = append([]string{context}, contexts...)
-		} else {
-			contexts = append(contexts, context)
+		if context != rawConfig.CurrentContext && !isContextShown(context) {
+			continue
 		}
+		contexts = append(contexts, context)
 	}
-	return contexts
+	return orderContexts(contexts, rawConfig.CurrentContext)
 }
 
 func applyContextChange(rawConfig api.Config, contextName string) error {
 	if rawConfig.Contexts[contextName] == nil {
 		return fmt.Errorf("context %q not found in kubeconfig", contextName)
 	}
-	rawConfig.CurrentContext = contextName
-	setConfig(rawConfig)
-	return nil
+	if err := confirmSwitch(rawConfig, contextName); err != nil {
+		return err
+	}
+	return updateKubeConfig(func(c *api.Config) error {
+		if c.Contexts[contextName] == nil {
+			return fmt.Errorf("context %q not found in kubeconfig", contextName)
+		}
+		c.CurrentContext = contextName
+		return nil
+	})
 }
 
 func selectContext(rawConfig api.Config) api.Config {
 	contexts := getContextNames(rawConfig)
 
-	selectedContext := showPrompt(contexts)
+	selectedContext := showSuggestPrompt(contextSuggestions(rawConfig, contexts))
 
 	if !validateSelection(contexts, selectedContext) {
 		fail(fmt.Sprintf("'%s' is not a valid context selection", selectedContext))
@@ -258,18 +417,44 @@ func selectContext(rawConfig api.Config) api.Config {
 	return rawConfig
 }
 
-func listNamespaces(cfgPath string) ([]string, error) {
-	restConfig, err := clientcmd.BuildConfigFromFlags("", cfgPath)
-	if err != nil {
-		return nil, err
+// switchContext is the non-interactive counterpart of selectContext, used for
+// "sk <context>". The name may be an exact match, a unique prefix or a unique
+// fuzzy match.
+func switchContext(rawConfig api.Config, query string) api.Config {
+	selectedContext, err := resolveContext(rawConfig, query)
+	checkErr(err)
+
+	checkErr(applyContextChange(rawConfig, selectedContext))
+	rawConfig.CurrentContext = selectedContext
+
+	return rawConfig
+}
+
+// resolveContext returns the context query names: an exact match, a unique
+// prefix or a unique fuzzy match.
+func resolveContext(rawConfig api.Config, query string) (string, error) {
+	if rawConfig.Contexts[query] != nil {
+		return query, nil
 	}
+	return matchSelection("context", getContextNames(rawConfig), query)
+}
 
-	clientset, err := kubernetes.NewForConfig(restConfig)
+func listNamespaces(cfgPath string) ([]string, error) {
+	return listContextNamespaces(cfgPath, "")
+}
+
+// listContextNamespaces lists the namespaces of contextName, or of the current
+// context when empty.
+func listContextNamespaces(cfgPath, contextName string) ([]string, error) {
+	clientset, err := newClientset(cfgPath, contextName)
 	if err != nil {
 		return nil, err
 	}
 
-	nss, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	nss, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -281,61 +466,210 @@ func listNamespaces(cfgPath string) ([]string, error) {
 	return names, nil
 }
 
+// newClientset returns a client for contextName, or for the current context
+// when empty.
+func newClientset(cfgPath, contextName string) (*kubernetes.Clientset, error) {
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		newLoadingRules(splitKubeConfigPath(cfgPath)),
+		&clientcmd.ConfigOverrides{CurrentContext: contextName},
+	).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	restConfig.Timeout = requestTimeout
+
+	return kubernetes.NewForConfig(restConfig)
+}
+
 func applyNamespaceChange(rawConfig api.Config, contextName, namespaceName string) error {
 	ctx, ok := rawConfig.Contexts[contextName]
 	if !ok || ctx == nil {
 		return fmt.Errorf("context %q not found in kubeconfig", contextName)
 	}
-	rawConfig.Contexts[contextName].Namespace = namespaceName
-	setConfig(rawConfig)
-	return nil
+	return updateKubeConfig(func(c *api.Config) error {
+		if c.Contexts[contextName] == nil {
+			return fmt.Errorf("context %q not found in kubeconfig", contextName)
+		}
+		c.Contexts[contextName].Namespace = namespaceName
+		return nil
+	})
 }
 
 func applyFavorite(rawConfig api.Config, contextName, namespaceName string) error {
+	if rawConfig.Contexts[contextName] != nil {
+		if err := confirmSwitch(rawConfig, contextName); err != nil {
+			return err
+		}
+	}
+	return updateKubeConfig(func(c *api.Config) error {
+		return selectContextAndNamespace(c, contextName, namespaceName)
+	})
+}
+
+// selectContextAndNamespace makes contextName current in rawConfig and sets
+// its namespace, without writing anything.
+func selectContextAndNamespace(rawConfig *api.Config, contextName, namespaceName string) error {
 	if rawConfig.Contexts[contextName] == nil {
 		return fmt.Errorf("context %q not found in kubeconfig", contextName)
 	}
 	rawConfig.CurrentContext = contextName
 	rawConfig.Contexts[contextName].Namespace = namespaceName
-	setConfig(rawConfig)
 	return nil
 }
 
 func selectNamespace(rawConfig api.Config) {
 	selectedContext := rawConfig.CurrentContext
+	currentNamespace := rawConfig.Contexts[selectedContext].Namespace
 
-	allNs, err := listNamespaces(kubeConfigPath)
-	checkErr(err)
+	suggestions := &promptSuggestions{}
+	// Only namespaces that are listed can be picked, unless the list may be
+	// incomplete
+	freeText := false
+	if cached, ok := readNamespaceCache(rawConfig, selectedContext); ok {
+		// Open the prompt right away and refresh the list behind it
+		suggestions.set(orderNamespaces(selectedContext, cached, currentNamespace))
+		go func() {
+			allNs, complete, err := namespaceChoices(rawConfig, selectedContext)
+			if err == nil && complete {
+				suggestions.set(orderNamespaces(selectedContext, allNs, currentNamespace))
+			}
+		}()
+	} else {
+		var allNs []string
+		var complete bool
+		var err error
+		withSpinner("Loading namespaces", func() {
+			allNs, complete, err = namespaceChoices(rawConfig, selectedContext)
+		})
+		if err != nil {
+			// Let the user set the namespace by hand instead
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				checkErr(err)
+			}
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, "Type the namespace to use, or press Ctrl-C to cancel.")
+			allNs = knownNamespaces(selectedContext, currentNamespace)
+			freeText = true
+		} else if !complete {
+			fmt.Fprintln(os.Stderr, "Not allowed to list namespaces, showing known ones. Type any other namespace to use it.")
+			freeText = true
+		}
+		suggestions.set(orderNamespaces(selectedContext, allNs, currentNamespace))
+	}
 
-	// Current value on top
-	currentNamespace := rawConfig.Contexts[selectedContext].Namespace
-	nsNames := []string{}
-	for _, name := range allNs {
-		if name == currentNamespace {
-			nsNames = append([]string{name}, nsNames...)
+	nsSelection := showDynamicPrompt(suggestions.get)
+
+	if !validNamespaceSelection(suggestions.names(), nsSelection, freeText) {
+		fail(fmt.Sprintf("'%s' is not a valid namespace selection", nsSelection))
+	}
+
+	checkErr(applyNamespaceChange(rawConfig, selectedContext, nsSelection))
+}
+
+// validNamespaceSelection reports whether selection is one of the listed
+// namespaces, or, with freeText, any valid namespace name.
+func validNamespaceSelection(listed []string, selection string, freeText bool) bool {
+	return validateSelection(listed, selection) || (freeText && validNamespaceName(selection))
+}
+
+// currentFirst returns names with current, if present, moved to the top.
+func currentFirst(names []string, current string) []string {
+	sorted := []string{}
+	for _, name := range names {
+		if name == current {
+			sorted = append([]string{name}, sorted...)
 		} else {
-			nsNames = append(nsNames, name)
+			sorted = append(sorted, name)
 		}
 	}
+	return sorted
+}
+
+// switchNamespace is the non-interactive counterpart of selectNamespace.
+func switchNamespace(rawConfig api.Config, query string) {
+	selectedContext := rawConfig.CurrentContext
+	selectedNamespace, err := resolveNamespace(rawConfig, selectedContext, query)
+	checkErr(err)
+	checkErr(applyNamespaceChange(rawConfig, selectedContext, selectedNamespace))
+}
 
-	nsSelection := showPrompt(nsNames)
+// resolveNamespace returns the namespace of contextName query names, matched
+// like a context. When the namespaces can't all be listed, it's matched
+// against the ones sk knows of instead, and a query that matches none of
+// them is only used as given when it's a valid namespace name.
+func resolveNamespace(rawConfig api.Config, contextName, query string) (string, error) {
+	// A match in the cache saves a round trip, anything else is checked live
+	if cached, ok := readNamespaceCache(rawConfig, contextName); ok {
+		if match, err := matchSelection("namespace", cached, query); err == nil {
+			return match, nil
+		}
+	}
 
-	if !validateSelection(nsNames, nsSelection) {
-		fail(fmt.Sprintf("'%s' is not a valid namespace selection", selectedContext))
+	var allNs []string
+	var complete bool
+	var err error
+	withSpinner("Loading namespaces", func() {
+		allNs, complete, err = namespaceChoices(rawConfig, contextName)
+	})
+	if err != nil {
+		currentNamespace := ""
+		if ctx := rawConfig.Contexts[contextName]; ctx != nil {
+			currentNamespace = ctx.Namespace
+		}
+		allNs = knownNamespaces(contextName, currentNamespace)
 	}
 
-	checkErr(applyNamespaceChange(rawConfig, selectedContext, nsSelection))
+	match, matchErr := matchSelection("namespace", allNs, query)
+	if matchErr == nil {
+		return match, nil
+	}
+	if complete || !validNamespaceName(query) {
+		if err != nil {
+			return "", fmt.Errorf("%w\n%s", err, matchErr)
+		}
+		return "", matchErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\nUsing namespace '%s' as given.\n", err, query)
+	}
+	return query, nil
 }
 
-func completer(suggestions []string) func(in prompt.Document) []prompt.Suggest {
+func completer(suggestions func() []prompt.Suggest) func(in prompt.Document) []prompt.Suggest {
 	return func(in prompt.Document) []prompt.Suggest {
-		s := []prompt.Suggest{}
-		for _, suggestion := range suggestions {
-			s = append(s, prompt.Suggest{Text: suggestion})
-		}
+		return filterSuggestions(suggestions(), in.GetWordBeforeCursor(), skCfg.Prompt.MatchDetails)
+	}
+}
+
+// promptSuggestions can be updated while a prompt is showing them, e.g. when
+// a background refresh completes.
+type promptSuggestions struct {
+	mu          sync.Mutex
+	suggestions []string
+}
+
+func (p *promptSuggestions) set(suggestions []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.suggestions = suggestions
+}
+
+func (p *promptSuggestions) names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.suggestions
+}
 
-		return prompt.FilterFuzzy(s, in.GetWordBeforeCursor(), true)
+func (p *promptSuggestions) get() []prompt.Suggest {
+	return toSuggestions(p.names())
+}
+
+func toSuggestions(suggestions []string) []prompt.Suggest {
+	s := []prompt.Suggest{}
+	for _, suggestion := range suggestions {
+		s = append(s, prompt.Suggest{Text: suggestion})
 	}
+	return s
 }
 
 func executor(in string) {
@@ -351,23 +685,46 @@ func executor(in string) {
 }
 
 func showPrompt(suggestions []string) string {
+	return showSuggestPrompt(toSuggestions(suggestions))
+}
+
+// showSuggestPrompt is showPrompt for suggestions carrying a description.
+func showSuggestPrompt(suggestions []prompt.Suggest) string {
+	return showDynamicPrompt(func() []prompt.Suggest { return suggestions })
+}
+
+// showDynamicPrompt is showPrompt for suggestions that may change while the
+// prompt is open. They're picked up on the next keystroke.
+func showDynamicPrompt(suggestions func() []prompt.Suggest) string {
+
+	// Render on stderr when stdout is captured, e.g. by eval "$(sk -s)".
+	out, writer := os.Stdout, prompt.NewStandardOutputWriter()
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		out, writer = os.Stderr, prompt.NewStderrWriter()
+	}
 
-	_, height, err := term.GetSize(int(os.Stdout.Fd()))
+	_, height, err := term.GetSize(int(out.Fd()))
 	if err != nil {
 		fmt.Printf("Couldn't get terminal size: %s\n", err.Error())
 		os.Exit(1)
 	}
+	style := skCfg.withDefaults().Prompt
+	maxSuggestions := uint16(height - 2)
+	if style.MaxSuggestions > 0 {
+		maxSuggestions = min(style.MaxSuggestions, maxSuggestions)
+	}
 
 	p := prompt.New(
 		executor,
 		completer(suggestions),
-		prompt.OptionPreviewSuggestionTextColor(prompt.Blue),
-		prompt.OptionSelectedSuggestionBGColor(prompt.LightGray),
-		prompt.OptionSuggestionBGColor(prompt.DarkGray),
-		prompt.OptionMaxSuggestion(uint16(height-2)),
+		prompt.OptionWriter(writer),
+		prompt.OptionPreviewSuggestionTextColor(promptColors[style.PreviewColor]),
+		prompt.OptionSelectedSuggestionBGColor(promptColors[style.SelectedSuggestionBackground]),
+		prompt.OptionSuggestionBGColor(promptColors[style.SuggestionBackground]),
+		prompt.OptionMaxSuggestion(maxSuggestions),
 		prompt.OptionCompletionOnDown(),
 		prompt.OptionShowCompletionAtStart(),
-		prompt.OptionPrefix(" ⎈ "),
+		prompt.OptionPrefix(style.Prefix),
 		prompt.OptionAddKeyBind(prompt.KeyBind{
 			Key: prompt.ControlC,
 			Fn: func(_ *prompt.Buffer) {
@@ -394,7 +751,7 @@ func validateSelection(selections []string, selection string) bool {
 
 func loadConfig() clientcmd.ClientConfig {
 	client := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeConfigPath},
+		newLoadingRules(splitKubeConfigPath(kubeConfigPath)),
 		&clientcmd.ConfigOverrides{
 			CurrentContext: "",
 		})
@@ -403,8 +760,36 @@ func loadConfig() clientcmd.ClientConfig {
 }
 
 func setConfig(c api.Config) {
-	err := clientcmd.ModifyConfig(clientcmd.NewDefaultPathOptions(), c, true)
-	checkErr(err)
+	checkErr(storeConfig(c))
+}
+
+// storeConfig writes c to the session when one is used or requested, and to
+// the kubeconfig otherwise.
+func storeConfig(c api.Config) error {
+	if activeSession() == "" && sessionRequested {
+		if err := startSession(); err != nil {
+			return err
+		}
+	}
+	if session := activeSession(); session != "" {
+		return writeSession(session, c)
+	}
+	// "sk shell" works on a copy of the kubeconfig, removed when it exits
+	return storeKubeConfig(c, os.Getenv(shellPinEnv) == "")
+}
+
+// storeKubeConfig writes c to the overlay when it's used, or to the
+// kubeconfig files otherwise. With journal, the change is added to the
+// journal so it can be undone.
+func storeKubeConfig(c api.Config, journal bool) error {
+	c, err := useOverlay(c)
+	if err != nil {
+		return err
+	}
+	if overlay := activeOverlay(); overlay != "" {
+		return writeSession(overlay, c)
+	}
+	return modifyKubeConfig(c, journal)
 }
 
 func resolveKubeConfigPath() string {
@@ -416,6 +801,83 @@ func resolveKubeConfigPath() string {
 	return filepath.Join(homedir.HomeDir(), ".kube", "config")
 }
 
+// splitKubeConfigPath splits a $KUBECONFIG style list into its files, in
+// precedence order, dropping empty entries and duplicates like kubectl does.
+func splitKubeConfigPath(cfgPath string) []string {
+	paths := []string{}
+	for _, p := range filepath.SplitList(cfgPath) {
+		if p == "" || slices.Contains(paths, p) {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// newLoadingRules keeps the strict single-file behaviour (a missing file is an
+// error) and uses the standard precedence merge when several files are listed.
+func newLoadingRules(paths []string) *clientcmd.ClientConfigLoadingRules {
+	if len(paths) == 1 {
+		return &clientcmd.ClientConfigLoadingRules{ExplicitPath: paths[0]}
+	}
+	return &clientcmd.ClientConfigLoadingRules{Precedence: paths}
+}
+
+// kubeConfigAccess tells clientcmd.ModifyConfig which files make up the
+// kubeconfig. Contexts are written back to the file they were loaded from
+// (LocationOfOrigin); current-context goes to the file that owns that key.
+type kubeConfigAccess struct {
+	paths []string
+}
+
+func newKubeConfigAccess(cfgPath string) kubeConfigAccess {
+	return kubeConfigAccess{paths: splitKubeConfigPath(cfgPath)}
+}
+
+func (a kubeConfigAccess) GetLoadingPrecedence() []string {
+	return a.paths
+}
+
+func (a kubeConfigAccess) GetStartingConfig() (*api.Config, error) {
+	return newLoadingRules(a.paths).Load()
+}
+
+// GetDefaultFilename returns the first file that sets current-context, since
+// that is the one winning the merge. Falls back to the first existing file.
+func (a kubeConfigAccess) GetDefaultFilename() string {
+	firstExisting := ""
+	for _, p := range a.paths {
+		cfg, err := clientcmd.LoadFromFile(p)
+		if err != nil {
+			continue
+		}
+		if cfg.CurrentContext != "" {
+			return p
+		}
+		if firstExisting == "" {
+			firstExisting = p
+		}
+	}
+	if firstExisting != "" {
+		return firstExisting
+	}
+	if len(a.paths) > 0 {
+		return a.paths[0]
+	}
+	return ""
+}
+
+func (a kubeConfigAccess) IsExplicitFile() bool {
+	return len(a.paths) == 1
+}
+
+func (a kubeConfigAccess) GetExplicitFile() string {
+	if a.IsExplicitFile() {
+		return a.paths[0]
+	}
+	return ""
+}
+
 func checkErr(err error) {
 	if err != nil {
 		fail(err.Error())
@@ -427,13 +889,23 @@ func fail(msg string) {
 	log.Fatal(msg)
 }
 
+// resolveSkDir returns $XDG_STATE_HOME/sk when XDG_STATE_HOME is set, and
+// ~/.sk otherwise. An existing ~/.sk keeps being used, so nothing is lost.
 func resolveSkDir() string {
 	userHome, err := os.UserHomeDir()
 	if err != nil {
 		// best effort
 		return ".sk"
 	}
-	return path.Join(userHome, ".sk")
+	legacy := path.Join(userHome, ".sk")
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		return legacy
+	}
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy
+	}
+	return path.Join(stateHome, "sk")
 }
 
 func readValue(key string) string {
@@ -451,38 +923,43 @@ func readValue(key string) string {
 }
 
 func storeValue(key, value string) error {
-	p := path.Join(skDir, key)
-
-	// Create or truncate
-	f, err := os.Create(p)
-	if err != nil {
-		return err
-	}
-
-	_, err = f.WriteString(value)
-	if err != nil {
-		f.Close()
-		return err
-	}
-
-	return f.Close()
+	return withStateLock(func() error {
+		return writeFileAtomic(path.Join(skDir, key), []byte(value))
+	})
 }
 
 // storePreviousState writes ctx and ns as a single atomic operation so that a
 // concurrent sk -p can never observe a torn state (new context + old namespace).
 func storePreviousState(ctx, ns string) error {
-	dest := path.Join(skDir, previousStateFile)
+	return writeFileAtomic(path.Join(skDir, previousStateKey()), []byte(fmt.Sprintf("%s\n%s", ctx, ns)))
+}
+
+// previousStateKey keeps a separate previous state per session, so -p in one
+// shell doesn't jump to where another shell was.
+func previousStateKey() string {
+	return sessionStateKey(previousStateFile)
+}
+
+// sessionStateKey returns where to keep the state file named key: next to
+// the session file when a session is active, in skDir otherwise.
+func sessionStateKey(key string) string {
+	if session := activeSession(); session != "" {
+		return path.Join(sessionsDirName, strings.TrimSuffix(filepath.Base(session), filepath.Ext(session))+"_"+key)
+	}
+	return key
+}
 
-	// Write to a sibling temp file, then rename into place. On POSIX systems
-	// rename(2) is atomic within the same filesystem, guaranteeing readers
-	// always see either the old complete state or the new complete state.
-	tmp, err := os.CreateTemp(skDir, previousStateFile+".tmp*")
+// writeFileAtomic writes data to a sibling temp file, then renames it into
+// place. On POSIX systems rename(2) is atomic within the same filesystem,
+// guaranteeing readers always see either the old or the new complete file.
+func writeFileAtomic(dest string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".tmp*")
 	if err != nil {
 		return err
 	}
 	tmpName := tmp.Name()
 
-	_, err = fmt.Fprintf(tmp, "%s\n%s", ctx, ns)
+	_, err = tmp.Write(data)
 	if err != nil {
 		tmp.Close()
 		os.Remove(tmpName)
@@ -501,7 +978,7 @@ func storePreviousState(ctx, ns string) error {
 // Falls back to the legacy two-file format (previous_context / previous_namespace)
 // and migrates it to the new format on first read.
 func readPreviousState() (ctx, ns string) {
-	p := path.Join(skDir, previousStateFile)
+	p := path.Join(skDir, previousStateKey())
 	data, err := os.ReadFile(p)
 	if err == nil {
 		parts := strings.SplitN(string(data), "\n", 2)
@@ -512,6 +989,10 @@ func readPreviousState() (ctx, ns string) {
 	if !os.IsNotExist(err) {
 		checkErr(err)
 	}
+	if previousStateKey() != previousStateFile {
+		// Sessions never had the legacy format.
+		return "", ""
+	}
 
 	// Legacy migration: read the old two-file format and promote to the new
 	// atomic single-file format so the next read is already migrated.
@@ -527,17 +1008,41 @@ func readPreviousState() (ctx, ns string) {
 }
 
 func createSkDir() error {
-	err := os.Mkdir(skDir, os.ModePerm)
-	if err == nil || strings.Contains(err.Error(), "file exists") {
-		return nil
-	}
-
-	return err
+	// The parent, like $XDG_STATE_HOME, may not exist yet either
+	return os.MkdirAll(skDir, os.ModePerm)
 }
 
 func printCurrentContextAndNamespace(rawConfig api.Config) {
-	currentContext := rawConfig.CurrentContext
-	currentNamespace := rawConfig.Contexts[currentContext].Namespace
+	currentContext, currentNamespace := currentContextAndNamespace(rawConfig)
 	fmt.Printf("Current context: %s\n", currentContext)
 	fmt.Printf("Current namespace: %s\n", currentNamespace)
 }
+
+// printPrompt prints "context/namespace" for the shell prompt segment set up
+// by "sk init", and nothing when there is no current context. Inside
+// "sk shell" it is prefixed with "shell:".
+func printPrompt() {
+	if err := checkRevert(); err != nil {
+		return
+	}
+	rawConfig, err := loadConfig().RawConfig()
+	if err != nil {
+		return
+	}
+	currentContext, currentNamespace := currentContextAndNamespace(rawConfig)
+	if currentContext == "" {
+		return
+	}
+	if os.Getenv(shellPinEnv) != "" {
+		fmt.Print("shell:")
+	}
+	fmt.Printf("%s/%s\n", currentContext, currentNamespace)
+}
+
+func currentContextAndNamespace(rawConfig api.Config) (string, string) {
+	currentContext := rawConfig.CurrentContext
+	if ctx := rawConfig.Contexts[currentContext]; ctx != nil {
+		return currentContext, ctx.Namespace
+	}
+	return currentContext, ""
+}
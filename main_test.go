@@ -1,10 +1,15 @@
 package main
 
 import (
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
 )
 
 func TestCreateSkDir_IdempotentOnRepeatCalls(t *testing.T) {
@@ -17,3 +22,94 @@ func TestCreateSkDir_IdempotentOnRepeatCalls(t *testing.T) {
 	assert.NoError(t, createSkDir())
 	assert.NoError(t, createSkDir())
 }
+
+// writeKubeConfigFile writes a kubeconfig holding one context per name, each
+// with its own cluster and user, and returns its path.
+func writeKubeConfigFile(t *testing.T, dir, file, currentContext string, contexts ...string) string {
+	t.Helper()
+	cfg := api.NewConfig()
+	cfg.CurrentContext = currentContext
+	for _, name := range contexts {
+		cfg.Clusters[name] = &api.Cluster{Server: "https://" + name + ".example.com"}
+		cfg.AuthInfos[name] = &api.AuthInfo{Token: name}
+		cfg.Contexts[name] = &api.Context{Cluster: name, AuthInfo: name, Namespace: "default"}
+	}
+	p := filepath.Join(dir, file)
+	require.NoError(t, clientcmd.WriteToFile(*cfg, p))
+	return p
+}
+
+// setKubeConfigPath points sk at cfgPath for the duration of the test.
+func setKubeConfigPath(t *testing.T, cfgPath string) {
+	t.Helper()
+	orig := kubeConfigPath
+	kubeConfigPath = cfgPath
+	t.Cleanup(func() { kubeConfigPath = orig })
+}
+
+func TestMultiFileKubeConfig_WritesChangesToOwningFile(t *testing.T) {
+	setSkDir(t)
+	dir := t.TempDir()
+	a := writeKubeConfigFile(t, dir, "a.yaml", "", "ctx-a")
+	b := writeKubeConfigFile(t, dir, "b.yaml", "ctx-b", "ctx-b")
+	setKubeConfigPath(t, strings.Join([]string{a, b}, string(filepath.ListSeparator)))
+
+	cfg, err := loadConfig().RawConfig()
+	require.NoError(t, err)
+	require.Equal(t, "ctx-b", cfg.CurrentContext)
+	assert.ElementsMatch(t, []string{"ctx-a", "ctx-b"}, getContextNames(cfg))
+
+	require.NoError(t, applyContextChange(cfg, "ctx-a"))
+	cfg, err = loadConfig().RawConfig()
+	require.NoError(t, err)
+	require.NoError(t, applyNamespaceChange(cfg, "ctx-a", "payments"))
+
+	// b.yaml owns current-context, a.yaml owns the ctx-a stanza.
+	fileA, err := clientcmd.LoadFromFile(a)
+	require.NoError(t, err)
+	fileB, err := clientcmd.LoadFromFile(b)
+	require.NoError(t, err)
+	assert.Empty(t, fileA.CurrentContext)
+	assert.Equal(t, "payments", fileA.Contexts["ctx-a"].Namespace)
+	assert.NotContains(t, fileA.Contexts, "ctx-b")
+	assert.Equal(t, "ctx-a", fileB.CurrentContext)
+	assert.NotContains(t, fileB.Contexts, "ctx-a")
+}
+
+func TestMultiFileKubeConfig_SkipsMissingFiles(t *testing.T) {
+	setSkDir(t)
+	dir := t.TempDir()
+	a := writeKubeConfigFile(t, dir, "a.yaml", "ctx-a", "ctx-a")
+	missing := filepath.Join(dir, "missing.yaml")
+	setKubeConfigPath(t, strings.Join([]string{missing, a}, string(filepath.ListSeparator)))
+
+	cfg, err := loadConfig().RawConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "ctx-a", cfg.CurrentContext)
+
+	require.NoError(t, applyNamespaceChange(cfg, "ctx-a", "kube-system"))
+	_, err = os.Stat(missing)
+	assert.True(t, os.IsNotExist(err), "no file should be created for a missing entry")
+}
+
+func TestSplitKubeConfigPath_DropsEmptyAndDuplicateEntries(t *testing.T) {
+	sep := string(filepath.ListSeparator)
+	assert.Equal(t, []string{"a", "b"}, splitKubeConfigPath("a"+sep+sep+"b"+sep+"a"))
+	assert.Equal(t, []string{"a"}, splitKubeConfigPath("a"))
+}
+
+func TestAllowBareFlags(t *testing.T) {
+	assert.Equal(t, []string{"-f="}, allowBareFlags([]string{"-f"}, "f"))
+	assert.Equal(t, []string{"-f=", "-n"}, allowBareFlags([]string{"-f", "-n"}, "f"))
+	assert.Equal(t, []string{"--f=", "-"}, allowBareFlags([]string{"--f", "-"}, "f"))
+	assert.Equal(t, []string{"-f", "prod"}, allowBareFlags([]string{"-f", "prod"}, "f"))
+	assert.Equal(t, []string{"-F"}, allowBareFlags([]string{"-F"}, "f"))
+	assert.Equal(t, []string{"--", "-f"}, allowBareFlags([]string{"--", "-f"}, "f"))
+}
+
+func loadRawConfig(t *testing.T) api.Config {
+	t.Helper()
+	cfg, err := loadConfig().RawConfig()
+	require.NoError(t, err)
+	return cfg
+}
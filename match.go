@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	prompt "github.com/c-bata/go-prompt"
+)
+
+// ambiguousMatchError is returned when a query matches more than one candidate.
+type ambiguousMatchError struct {
+	kind    string
+	query   string
+	matches []string
+}
+
+func (e *ambiguousMatchError) Error() string {
+	return fmt.Sprintf("'%s' matches more than one %s:\n  %s", e.query, e.kind, strings.Join(e.matches, "\n  "))
+}
+
+// matchSelection resolves query against candidates without a prompt. An exact
+// match wins, then a unique prefix, then a unique fuzzy match using the same
+// rules as completer. kind is only used in error messages.
+func matchSelection(kind string, candidates []string, query string) (string, error) {
+	if validateSelection(candidates, query) {
+		return query, nil
+	}
+
+	prefixed := []string{}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, query) {
+			prefixed = append(prefixed, c)
+		}
+	}
+	if len(prefixed) == 1 {
+		return prefixed[0], nil
+	}
+	if len(prefixed) > 1 {
+		return "", &ambiguousMatchError{kind: kind, query: query, matches: prefixed}
+	}
+
+	fuzzy := []string{}
+	for _, s := range prompt.FilterFuzzy(toSuggestions(candidates), query, true) {
+		fuzzy = append(fuzzy, s.Text)
+	}
+	switch len(fuzzy) {
+	case 0:
+		return "", fmt.Errorf("'%s' does not match any %s", query, kind)
+	case 1:
+		return fuzzy[0], nil
+	default:
+		return "", &ambiguousMatchError{kind: kind, query: query, matches: fuzzy}
+	}
+}
+
+// closestMatch returns the candidate most likely meant by a mistyped query: a
+// unique prefix or fuzzy match, or else the one with the smallest edit
+// distance if that is small enough to be a typo. Returns "" otherwise.
+func closestMatch(candidates []string, query string) string {
+	if match, err := matchSelection("", candidates, query); err == nil {
+		return match
+	}
+
+	best, bestDistance := "", max(2, len(query)/3)+1
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(c), strings.ToLower(query)); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
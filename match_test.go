@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchSelection(t *testing.T) {
+	candidates := []string{"prod-eu", "prod-us", "staging-eu", "dev"}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"exact match", "dev", "dev"},
+		{"unique prefix", "stag", "staging-eu"},
+		{"unique fuzzy match", "pdeu", "prod-eu"},
+		{"fuzzy match ignores case", "PDUS", "prod-us"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchSelection("context", candidates, tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMatchSelection_ExactMatchWinsOverPrefix(t *testing.T) {
+	got, err := matchSelection("context", []string{"prod", "prod-eu"}, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "prod", got)
+}
+
+func TestMatchSelection_AmbiguousListsCandidates(t *testing.T) {
+	_, err := matchSelection("context", []string{"prod-eu", "prod-us", "dev"}, "prod")
+
+	var ambiguous *ambiguousMatchError
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Equal(t, []string{"prod-eu", "prod-us"}, ambiguous.matches)
+	assert.Contains(t, err.Error(), "prod-eu")
+	assert.Contains(t, err.Error(), "prod-us")
+}
+
+func TestMatchSelection_NoMatch(t *testing.T) {
+	_, err := matchSelection("namespace", []string{"default", "kube-system"}, "payments")
+	assert.EqualError(t, err, "'payments' does not match any namespace")
+}
+
+func TestClosestMatch(t *testing.T) {
+	candidates := []string{"prod-eu", "prod-us", "staging", "dev"}
+
+	assert.Equal(t, "staging", closestMatch(candidates, "stagnig"))
+	assert.Equal(t, "prod-eu", closestMatch(candidates, "prod-eux"))
+	assert.Equal(t, "dev", closestMatch(candidates, "de"))
+	assert.Empty(t, closestMatch(candidates, "prod"), "ambiguous prefix")
+	assert.Empty(t, closestMatch(candidates, "kube-system"))
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("prod", "prod"))
+	assert.Equal(t, 1, editDistance("prod", "prd"))
+	assert.Equal(t, 2, editDistance("prod", "pdro"))
+	assert.Equal(t, 4, editDistance("", "prod"))
+}
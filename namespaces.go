@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path"
+	"slices"
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// knownNamespacesFile remembers, per context, the namespaces switched to
+	// most recently. They are offered when namespaces can't be listed.
+	knownNamespacesFile    = "known_namespaces.yaml"
+	maxKnownNamespaces     = 20
+	namespaceProbeResource = "pods"
+)
+
+// namespaceChoices returns the namespaces to offer for contextName, straight
+// from the cluster, and caches them. When the user isn't allowed to list
+// namespaces, it falls back to the ones configured for the context, plus
+// remembered ones and the current one if a SelfSubjectAccessReview says they
+// can be used. complete is false in that case, since there may be more
+// namespaces than the ones returned.
+func namespaceChoices(rawConfig api.Config, contextName string) (names []string, complete bool, err error) {
+	currentNamespace := ""
+	if ctx := rawConfig.Contexts[contextName]; ctx != nil {
+		currentNamespace = ctx.Namespace
+	}
+
+	names, err = listContextNamespaces(kubeConfigPath, contextName)
+	if err == nil {
+		// Best effort, the cache only saves time
+		_ = writeNamespaceCache(rawConfig, contextName, names)
+		return names, true, nil
+	}
+	if !apierrors.IsForbidden(err) {
+		return nil, false, classifyClusterError(contextName, err)
+	}
+
+	names = slices.Clone(skCfg.Contexts[contextName].Namespaces)
+	for _, ns := range probeNamespaces(contextName, knownNamespaces(contextName, currentNamespace)) {
+		if !slices.Contains(names, ns) {
+			names = append(names, ns)
+		}
+	}
+	return names, false, nil
+}
+
+// knownNamespaces returns the remembered namespaces of contextName followed by
+// currentNamespace and "default", without duplicates.
+func knownNamespaces(contextName, currentNamespace string) []string {
+	names := []string{}
+	for _, ns := range append(readKnownNamespaces()[contextName], currentNamespace, "default") {
+		if ns != "" && !slices.Contains(names, ns) {
+			names = append(names, ns)
+		}
+	}
+	return names
+}
+
+// probeNamespaces returns the candidates in which the user may list pods.
+// Candidates that can't be checked are kept.
+func probeNamespaces(contextName string, candidates []string) []string {
+	clientset, err := newClientset(kubeConfigPath, contextName)
+	if err != nil {
+		return nil
+	}
+
+	allowed := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i, ns := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			allowed[i] = canListPods(clientset, ns)
+		}()
+	}
+	wg.Wait()
+
+	names := []string{}
+	for i, ns := range candidates {
+		if allowed[i] {
+			names = append(names, ns)
+		}
+	}
+	return names
+}
+
+func canListPods(clientset kubernetes.Interface, namespace string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "list",
+				Resource:  namespaceProbeResource,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return true
+	}
+	return review.Status.Allowed
+}
+
+// validNamespaceName reports whether name can be typed in as a namespace that
+// wasn't offered.
+func validNamespaceName(name string) bool {
+	return len(validation.IsDNS1123Label(name)) == 0
+}
+
+// readKnownNamespaces returns the remembered namespaces per context, most
+// recent first.
+func readKnownNamespaces() map[string][]string {
+	known := map[string][]string{}
+	data, err := os.ReadFile(path.Join(skDir, knownNamespacesFile))
+	if err != nil {
+		return known
+	}
+	// Best effort, it's only used for suggestions.
+	_ = yaml.Unmarshal(data, &known)
+	return known
+}
+
+// rememberNamespace records namespace as the most recently used one for
+// contextName.
+func rememberNamespace(contextName, namespace string) error {
+	if contextName == "" || namespace == "" {
+		return nil
+	}
+
+	return withStateLock(func() error {
+		known := readKnownNamespaces()
+		namespaces := slices.DeleteFunc(known[contextName], func(ns string) bool { return ns == namespace })
+		namespaces = append([]string{namespace}, namespaces...)
+		if len(namespaces) > maxKnownNamespaces {
+			namespaces = namespaces[:maxKnownNamespaces]
+		}
+		known[contextName] = namespaces
+
+		data, err := yaml.Marshal(known)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(path.Join(skDir, knownNamespacesFile), data)
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// restrictedAPIServer fakes a cluster where listing namespaces is forbidden
+// and pods may only be listed in the allowed namespaces.
+func restrictedAPIServer(t *testing.T, allowed ...string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/namespaces":
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(metav1.Status{
+				TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+				Status:   metav1.StatusFailure,
+				Reason:   metav1.StatusReasonForbidden,
+				Code:     http.StatusForbidden,
+			})
+		case "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews":
+			// client-go sends protobuf; any supported encoding is accepted back.
+			body, _ := io.ReadAll(r.Body)
+			obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(body, nil, nil)
+			if !assert.NoError(t, err) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			review := obj.(*authorizationv1.SelfSubjectAccessReview)
+			review.Status.Allowed = slices.Contains(allowed, review.Spec.ResourceAttributes.Namespace)
+			_ = json.NewEncoder(w).Encode(review)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeServerKubeConfig(t *testing.T, server string) string {
+	t.Helper()
+	cfg := api.NewConfig()
+	cfg.CurrentContext = "restricted"
+	cfg.Clusters["restricted"] = &api.Cluster{Server: server}
+	cfg.AuthInfos["restricted"] = &api.AuthInfo{Token: "token"}
+	cfg.Contexts["restricted"] = &api.Context{Cluster: "restricted", AuthInfo: "restricted", Namespace: "team-a"}
+	p := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, clientcmd.WriteToFile(*cfg, p))
+	return p
+}
+
+func TestNamespaceChoices_FallsBackWhenListingIsForbidden(t *testing.T) {
+	setSkDir(t)
+	server := restrictedAPIServer(t, "team-a", "team-b")
+	setKubeConfigPath(t, writeServerKubeConfig(t, server.URL))
+
+	origCfg := skCfg
+	skCfg = skConfig{Contexts: map[string]contextConfig{"restricted": {Namespaces: []string{"team-c"}}}}
+	t.Cleanup(func() { skCfg = origCfg })
+
+	require.NoError(t, rememberNamespace("restricted", "team-b"))
+	require.NoError(t, rememberNamespace("restricted", "gone"))
+
+	names, complete, err := namespaceChoices(loadRawConfig(t), "restricted")
+	require.NoError(t, err)
+	assert.False(t, complete)
+	// Configured first, then remembered and current ones that pass the probe.
+	assert.Equal(t, []string{"team-c", "team-b", "team-a"}, names)
+}
+
+func TestRememberNamespace_MostRecentFirstAndBounded(t *testing.T) {
+	setSkDir(t)
+
+	for i := range maxKnownNamespaces + 5 {
+		require.NoError(t, rememberNamespace("ctx", string(rune('a'+i))))
+	}
+	require.NoError(t, rememberNamespace("ctx", "c"))
+
+	known := readKnownNamespaces()["ctx"]
+	assert.Len(t, known, maxKnownNamespaces)
+	assert.Equal(t, "c", known[0])
+	assert.Equal(t, "y", known[1])
+	assert.Equal(t, 1, slices.Index(known, "y"))
+	assert.NotContains(t, known[1:], "c")
+}
+
+func TestValidNamespaceName(t *testing.T) {
+	assert.True(t, validNamespaceName("team-a"))
+	assert.False(t, validNamespaceName("Team A"))
+	assert.False(t, validNamespaceName(""))
+}
+
+func TestValidNamespaceSelection_FreeTextOnlyWhenIncomplete(t *testing.T) {
+	listed := []string{"default", "payments"}
+	assert.True(t, validNamespaceSelection(listed, "payments", false))
+	assert.False(t, validNamespaceSelection(listed, "paymnts", false))
+	assert.True(t, validNamespaceSelection(listed, "paymnts", true))
+	assert.False(t, validNamespaceSelection(listed, "Not Valid", true))
+}
+
+func TestResolveNamespace_WhenClusterIsUnreachable(t *testing.T) {
+	setSkDir(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	setKubeConfigPath(t, writeServerKubeConfig(t, server.URL))
+	require.NoError(t, rememberNamespace("restricted", "payments"))
+	rawConfig := loadRawConfig(t)
+
+	ns, err := resolveNamespace(rawConfig, "restricted", "paym")
+	require.NoError(t, err)
+	assert.Equal(t, "payments", ns, "known namespaces are matched")
+
+	ns, err = resolveNamespace(rawConfig, "restricted", "orders")
+	require.NoError(t, err)
+	assert.Equal(t, "orders", ns, "a valid name is used as given")
+
+	_, err = resolveNamespace(rawConfig, "restricted", "Not_A_Namespace")
+	assert.Error(t, err)
+}
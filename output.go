@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/yaml"
+)
+
+// outputFormats are the values accepted by -o. They never prompt, so they
+// work without a TTY.
+var outputFormats = []string{"json", "yaml", "tsv"}
+
+// currentState is what "sk -c -o <format>" prints.
+type currentState struct {
+	Context    string `json:"context"`
+	Namespace  string `json:"namespace"`
+	Cluster    string `json:"cluster"`
+	Server     string `json:"server"`
+	User       string `json:"user"`
+	KubeConfig string `json:"kubeconfig"`
+}
+
+// favoriteOutput is a favorite together with its name, as printed by
+// "sk -l -o <format>".
+type favoriteOutput struct {
+	Name string `json:"name"`
+	favorite
+}
+
+func validateOutputFormat(format string) error {
+	for _, f := range outputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q, use one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+// currentStateOf describes the current context. Fields that can't be resolved,
+// e.g. a cluster missing from the kubeconfig, are left empty.
+func currentStateOf(rawConfig api.Config) currentState {
+	state := currentState{}
+	state.Context, state.Namespace = currentContextAndNamespace(rawConfig)
+	ctx := rawConfig.Contexts[state.Context]
+	if ctx == nil {
+		return state
+	}
+	state.Cluster = ctx.Cluster
+	state.User = ctx.AuthInfo
+	state.KubeConfig = ctx.LocationOfOrigin
+	if cluster := rawConfig.Clusters[ctx.Cluster]; cluster != nil {
+		state.Server = cluster.Server
+	}
+	return state
+}
+
+// printCurrentState prints the current context, namespace, cluster, server,
+// user and kubeconfig file in the given format. tsv is a single line with
+// the fields in that order.
+func printCurrentState(rawConfig api.Config, format string) error {
+	state := currentStateOf(rawConfig)
+	if format == "tsv" {
+		fmt.Println(strings.Join([]string{state.Context, state.Namespace, state.Cluster, state.Server, state.User, state.KubeConfig}, "\t"))
+		return nil
+	}
+	return printStructured(state, format)
+}
+
+// printFavoritesOutput prints all favorites sorted by name in the given
+// format. tsv has a line per favorite with name, context, namespace,
+// description, comma separated tags and creation time.
+func printFavoritesOutput(format string) error {
+	favorites := readFavorites()
+	out := []favoriteOutput{}
+	for _, name := range sortedFavoriteNames(favorites) {
+		out = append(out, favoriteOutput{Name: name, favorite: favorites[name]})
+	}
+
+	if format == "tsv" {
+		for _, f := range out {
+			createdAt := ""
+			if !f.CreatedAt.IsZero() {
+				createdAt = f.CreatedAt.Format(time.RFC3339)
+			}
+			fmt.Println(strings.Join([]string{f.Name, f.Context, f.Namespace, f.Description, strings.Join(f.Tags, ","), createdAt}, "\t"))
+		}
+		return nil
+	}
+	return printStructured(out, format)
+}
+
+func printStructured(v any, format string) error {
+	var data []byte
+	var err error
+	switch format {
+	case "json":
+		data, err = json.MarshalIndent(v, "", "  ")
+		data = append(data, '\n')
+	case "yaml":
+		data, err = yaml.Marshal(v)
+	default:
+		err = validateOutputFormat(format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureStdout captures whatever fn writes to os.Stdout and returns it as a string.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	origStdout := os.Stdout
+	os.Stdout = w
+	fn()
+	w.Close()
+	os.Stdout = origStdout
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, r)
+	require.NoError(t, err)
+	return buf.String()
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, f := range outputFormats {
+		assert.NoError(t, validateOutputFormat(f))
+	}
+	assert.EqualError(t, validateOutputFormat("xml"), `unknown output format "xml", use one of json, yaml, tsv`)
+}
+
+func TestPrintCurrentState_JSON(t *testing.T) {
+	cfgPath := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, cfgPath)
+	rawConfig := loadRawConfig(t)
+
+	output := captureStdout(t, func() { require.NoError(t, printCurrentState(rawConfig, "json")) })
+
+	var state currentState
+	require.NoError(t, json.Unmarshal([]byte(output), &state))
+	assert.Equal(t, currentState{
+		Context:    "ctx-a",
+		Namespace:  "default",
+		Cluster:    "ctx-a",
+		Server:     "https://ctx-a.example.com",
+		User:       "ctx-a",
+		KubeConfig: cfgPath,
+	}, state)
+}
+
+func TestPrintCurrentState_TSV(t *testing.T) {
+	cfgPath := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a")
+	setKubeConfigPath(t, cfgPath)
+	rawConfig := loadRawConfig(t)
+
+	output := captureStdout(t, func() { require.NoError(t, printCurrentState(rawConfig, "tsv")) })
+
+	assert.Equal(t, "ctx-a\tdefault\tctx-a\thttps://ctx-a.example.com\tctx-a\t"+cfgPath+"\n", output)
+}
+
+func TestPrintFavoritesOutput_SortedWithAllFields(t *testing.T) {
+	setSkDir(t)
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, saveFavorite("zeta", favorite{Context: "ctx-z", Namespace: "ns-z", CreatedAt: createdAt}, false))
+	require.NoError(t, saveFavorite("alpha", favorite{Context: "ctx-a", Namespace: "ns-a", Description: "EU payments", Tags: []string{"prod", "eu"}, CreatedAt: createdAt}, false))
+
+	output := captureStdout(t, func() { require.NoError(t, printFavoritesOutput("tsv")) })
+	assert.Equal(t, "alpha\tctx-a\tns-a\tEU payments\tprod,eu\t2026-01-02T03:04:05Z\n"+
+		"zeta\tctx-z\tns-z\t\t\t2026-01-02T03:04:05Z\n", output)
+
+	output = captureStdout(t, func() { require.NoError(t, printFavoritesOutput("yaml")) })
+	assert.Equal(t, `- context: ctx-a
+  createdAt: "2026-01-02T03:04:05Z"
+  description: EU payments
+  name: alpha
+  namespace: ns-a
+  tags:
+  - prod
+  - eu
+- context: ctx-z
+  createdAt: "2026-01-02T03:04:05Z"
+  name: zeta
+  namespace: ns-z
+`, output)
+}
+
+func TestPrintFavoritesOutput_EmptyJSONList(t *testing.T) {
+	dir := setSkDir(t)
+	require.NoFileExists(t, filepath.Join(dir, favoritesFile))
+
+	output := captureStdout(t, func() { require.NoError(t, printFavoritesOutput("json")) })
+	assert.Equal(t, "[]\n", output)
+}
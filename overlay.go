@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// The overlay is a kubeconfig in ~/.sk that is put first in $KUBECONFIG, like
+// a session, but shared by every shell and kept for good. sk writes the
+// current context and namespaces to it instead of to kubeconfig files it
+// can't write to, such as generated, mounted or Nix store files.
+const overlayFile = "overlay.yaml"
+
+// overlayStarted is set once this invocation put the overlay in front of the
+// kubeconfig path, meaning the caller needs to be told about the new
+// $KUBECONFIG.
+var overlayStarted bool
+
+func overlayPath() string {
+	return path.Join(skDir, overlayFile)
+}
+
+// activeOverlay returns the overlay file when $KUBECONFIG starts with it, ""
+// otherwise.
+func activeOverlay() string {
+	paths := splitKubeConfigPath(kubeConfigPath)
+	if len(paths) < 2 || filepath.Clean(paths[0]) != filepath.Clean(overlayPath()) {
+		return ""
+	}
+	return paths[0]
+}
+
+// startOverlay puts the overlay in front of the current kubeconfig path,
+// creating it when it doesn't exist yet. An existing overlay is kept, so
+// contexts changed in it earlier stay changed.
+func startOverlay() error {
+	if _, err := os.Stat(overlayPath()); os.IsNotExist(err) {
+		if err := clientcmd.WriteToFile(*api.NewConfig(), overlayPath()); err != nil {
+			return err
+		}
+	}
+
+	kubeConfigPath = strings.Join(append([]string{overlayPath()}, splitKubeConfigPath(kubeConfigPath)...), string(filepath.ListSeparator))
+	overlayStarted = true
+	return nil
+}
+
+// readOnlyKubeConfigFile returns the first kubeconfig file that changes
+// would be written to but can't be, or "" when there is none.
+func readOnlyKubeConfigFile(previous api.Config, changes []fieldChange) string {
+	access := newKubeConfigAccess(kubeConfigPath)
+	for _, change := range changes {
+		if p := kubeConfigFileFor(access, previous, change); !isWritable(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// isWritable reports whether the file at p can be written to in place. A
+// missing file is created, so it needs the closest existing directory to be
+// writable instead.
+func isWritable(p string) bool {
+	f, err := os.OpenFile(p, os.O_WRONLY, 0)
+	if err == nil {
+		f.Close()
+		return true
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+
+	dir := filepath.Dir(p)
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	f, err = os.CreateTemp(dir, ".sk-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return os.Remove(f.Name()) == nil
+}
+
+// useOverlay starts the overlay when it's configured, or when c can't be
+// written to the kubeconfig files, and explains why in the latter case. It
+// returns what to write then: the overlaid config with the values c changes,
+// so values an existing overlay already holds are kept.
+func useOverlay(c api.Config) (api.Config, error) {
+	if activeOverlay() != "" {
+		return c, nil
+	}
+	previous, err := loadConfig().RawConfig()
+	if err != nil {
+		return c, err
+	}
+	changes := fieldChanges(previous, c)
+	readOnly := readOnlyKubeConfigFile(previous, changes)
+	if !skCfg.Overlay && readOnly == "" {
+		return c, nil
+	}
+
+	if err := startOverlay(); err != nil {
+		return c, err
+	}
+	if readOnly != "" {
+		fmt.Fprintf(os.Stderr, "%s is read-only, so the context and namespace are kept in %s instead.\n", readOnly, overlayPath())
+		fmt.Fprintf(os.Stderr, "To use them in every shell, add this to your shell profile, after the line setting KUBECONFIG:\n  %s\n", overlayExport())
+	}
+
+	overlaid, err := loadConfig().RawConfig()
+	if err != nil {
+		return c, err
+	}
+	if !applyFieldChanges(&overlaid, changes) {
+		return c, fmt.Errorf("can't apply the change to %s", overlayPath())
+	}
+	return overlaid, nil
+}
+
+// overlayExport is the line putting the overlay in front of $KUBECONFIG, for
+// a shell profile.
+func overlayExport() string {
+	if os.Getenv("KUBECONFIG") == "" {
+		return sessionExport()
+	}
+	return fmt.Sprintf(`export KUBECONFIG=%s"%c$KUBECONFIG"`, shellQuote(overlayPath()), filepath.ListSeparator)
+}
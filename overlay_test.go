@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func resetOverlayStarted(t *testing.T) {
+	t.Helper()
+	orig := overlayStarted
+	t.Cleanup(func() { overlayStarted = orig })
+}
+
+func TestOverlay_LeavesKubeConfigUntouched(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{Overlay: true})
+	resetOverlayStarted(t)
+	shared := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, shared)
+	before, err := os.ReadFile(shared)
+	require.NoError(t, err)
+
+	require.NoError(t, applyFavorite(loadRawConfig(t), "ctx-b", "payments"))
+
+	after, err := os.ReadFile(shared)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+	assert.Empty(t, readJournal())
+
+	assert.True(t, overlayStarted)
+	assert.Equal(t, overlayPath(), activeOverlay())
+	assert.Equal(t, "export KUBECONFIG='"+overlayPath()+string(filepath.ListSeparator)+shared+"'", sessionExport())
+	cfg := loadRawConfig(t)
+	assert.Equal(t, "ctx-b", cfg.CurrentContext)
+	assert.Equal(t, "payments", cfg.Contexts["ctx-b"].Namespace)
+
+	overlayCfg, err := clientcmd.LoadFromFile(overlayPath())
+	require.NoError(t, err)
+	assert.Equal(t, "ctx-b", overlayCfg.CurrentContext)
+	assert.Len(t, overlayCfg.Contexts, 1)
+
+	// A new shell without the overlay in $KUBECONFIG picks up the same overlay
+	setKubeConfigPath(t, shared)
+	require.NoError(t, applyNamespaceChange(loadRawConfig(t), "ctx-a", "web"))
+	overlayCfg, err = clientcmd.LoadFromFile(overlayPath())
+	require.NoError(t, err)
+	assert.Equal(t, "ctx-b", overlayCfg.CurrentContext)
+	assert.Equal(t, "web", overlayCfg.Contexts["ctx-a"].Namespace)
+	assert.Equal(t, "payments", overlayCfg.Contexts["ctx-b"].Namespace)
+}
+
+func TestOverlay_UsedForReadOnlyKubeConfig(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only files")
+	}
+	setSkDir(t)
+	resetOverlayStarted(t)
+	shared := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b")
+	require.NoError(t, os.Chmod(shared, 0o400))
+	setKubeConfigPath(t, shared)
+
+	assert.Empty(t, readOnlyKubeConfigFile(loadRawConfig(t), nil), "nothing changes")
+
+	require.NoError(t, applyContextChange(loadRawConfig(t), "ctx-b"))
+
+	assert.Equal(t, overlayPath(), activeOverlay())
+	assert.Equal(t, "ctx-b", loadRawConfig(t).CurrentContext)
+}
+
+func TestReadOnlyKubeConfigFile_OnlyChecksChangedFiles(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only files")
+	}
+	dir := t.TempDir()
+	a := writeKubeConfigFile(t, dir, "a.yaml", "ctx-a", "ctx-a")
+	b := writeKubeConfigFile(t, dir, "b.yaml", "", "ctx-b")
+	require.NoError(t, os.Chmod(b, 0o400))
+	setKubeConfigPath(t, a+string(filepath.ListSeparator)+b)
+
+	previous := loadRawConfig(t)
+	cfg := loadRawConfig(t)
+	cfg.CurrentContext = "ctx-b"
+	assert.Empty(t, readOnlyKubeConfigFile(previous, fieldChanges(previous, cfg)))
+
+	cfg.Contexts["ctx-b"].Namespace = "payments"
+	assert.Equal(t, b, readOnlyKubeConfigFile(previous, fieldChanges(previous, cfg)))
+}
+
+func TestIsWritable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only files")
+	}
+	dir := t.TempDir()
+	assert.True(t, isWritable(filepath.Join(dir, "missing", "config")), "created in a writable directory")
+
+	readOnlyDir := filepath.Join(dir, "read-only")
+	require.NoError(t, os.Mkdir(readOnlyDir, 0o700))
+	existing := writeKubeConfigFile(t, readOnlyDir, "config", "ctx-a", "ctx-a")
+	require.NoError(t, os.Chmod(readOnlyDir, 0o500))
+	t.Cleanup(func() { _ = os.Chmod(readOnlyDir, 0o700) })
+	assert.True(t, isWritable(existing), "written in place")
+	assert.False(t, isWritable(filepath.Join(readOnlyDir, "missing")))
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"go.yaml.in/yaml/v3"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// textEdit replaces the bytes from start to end with text.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// patchKubeConfig writes c by changing only the bytes of the current-context
+// and namespace values in the kubeconfig files, leaving comments, key order
+// and everything else as they are. It returns false when c differs from
+// previous in other ways, a file can't be patched or writing one fails, so
+// the caller can fall back to rewriting the files. Nothing is written in the
+// first two cases.
+func patchKubeConfig(previous, c api.Config, changes []fieldChange) bool {
+	expected := previous.DeepCopy()
+	if !applyFieldChanges(expected, changes) || !reflect.DeepEqual(*expected, c) {
+		return false
+	}
+
+	access := newKubeConfigAccess(kubeConfigPath)
+	perFile := map[string][]fieldChange{}
+	for _, change := range changes {
+		p := kubeConfigFileFor(access, previous, change)
+		perFile[p] = append(perFile[p], change)
+	}
+
+	contents := map[string][]byte{}
+	for p, fileChanges := range perFile {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return false
+		}
+		result, ok := patchKubeConfigData(data, fileChanges)
+		if !ok {
+			return false
+		}
+		contents[p] = result
+	}
+
+	for _, p := range sortedKeys(contents) {
+		if err := replaceFile(p, contents[p]); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// applyFieldChanges makes changes to c. It returns false when a change is
+// for a context c doesn't have.
+func applyFieldChanges(c *api.Config, changes []fieldChange) bool {
+	for _, change := range changes {
+		switch change.Field {
+		case fieldCurrentContext:
+			c.CurrentContext = change.To
+		case fieldNamespace:
+			ctx := c.Contexts[change.Context]
+			if ctx == nil {
+				return false
+			}
+			ctx.Namespace = change.To
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// patchKubeConfigData makes changes to the kubeconfig in data. The result is
+// loaded again to check it holds exactly the changed config; ok is false
+// when it doesn't, or when data can't be patched.
+func patchKubeConfigData(data []byte, changes []fieldChange) (result []byte, ok bool) {
+	expected, err := clientcmd.Load(data)
+	if err != nil || !applyFieldChanges(expected, changes) {
+		return nil, false
+	}
+
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(data, &doc); err != nil || doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 {
+		return nil, false
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, false
+	}
+
+	edits := []textEdit{}
+	for _, change := range changes {
+		mapping, key := root, "current-context"
+		if change.Field == fieldNamespace {
+			mapping, key = contextNode(root, change.Context), "namespace"
+		}
+		if mapping == nil {
+			return nil, false
+		}
+		edit, ok := setMappingValue(data, mapping, key, change.To)
+		if !ok {
+			return nil, false
+		}
+		if edit != nil {
+			edits = append(edits, *edit)
+		}
+	}
+
+	// Back to front, so earlier offsets stay valid
+	slices.SortFunc(edits, func(a, b textEdit) int { return b.start - a.start })
+	result = slices.Clone(data)
+	for _, e := range edits {
+		result = slices.Concat(result[:e.start], []byte(e.text), result[e.end:])
+	}
+
+	patched, err := clientcmd.Load(result)
+	if err != nil || !reflect.DeepEqual(expected, patched) {
+		return nil, false
+	}
+	return result, true
+}
+
+// contextNode returns the "context" mapping of the named context.
+func contextNode(root *yaml.Node, name string) *yaml.Node {
+	contexts := mappingValue(root, "contexts")
+	if contexts == nil || contexts.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, item := range contexts.Content {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		if n := mappingValue(item, "name"); n != nil && n.Kind == yaml.ScalarNode && n.Value == name {
+			if ctx := mappingValue(item, "context"); ctx != nil && ctx.Kind == yaml.MappingNode {
+				return ctx
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue returns the edit setting key to value in mapping. The
+// value is written in the style of the one it replaces. A missing key is
+// added on its own line, before the first key sorting after it, like kubectl
+// orders them. The edit is nil when there's nothing to change.
+func setMappingValue(data []byte, mapping *yaml.Node, key, value string) (*textEdit, bool) {
+	if current := mappingValue(mapping, key); current != nil {
+		if current.Kind != yaml.ScalarNode || current.Tag == "!!null" {
+			return nil, false
+		}
+		start, ok := nodeOffset(data, current)
+		if !ok {
+			return nil, false
+		}
+		end, ok := scalarEnd(data, start, current)
+		if !ok {
+			return nil, false
+		}
+		text, ok := formatScalar(value, current.Style)
+		if !ok {
+			return nil, false
+		}
+		return &textEdit{start: start, end: end, text: text}, true
+	}
+
+	if value == "" {
+		return nil, true
+	}
+	if mapping.Style&yaml.FlowStyle != 0 || len(mapping.Content) == 0 {
+		return nil, false
+	}
+	next := mapping.Content[0]
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value > key {
+			next = mapping.Content[i]
+			break
+		}
+	}
+	offset, ok := nodeOffset(data, next)
+	if !ok {
+		return nil, false
+	}
+	lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+	indent := string(data[lineStart:offset])
+	if strings.Trim(indent, " ") != "" {
+		// The key doesn't start its line, e.g. "- name: x"
+		return nil, false
+	}
+	text, ok := formatScalar(value, 0)
+	if !ok {
+		return nil, false
+	}
+	return &textEdit{start: lineStart, end: lineStart, text: indent + key + ": " + text + "\n"}, true
+}
+
+// nodeOffset returns the byte offset of n in data. Columns count runes.
+func nodeOffset(data []byte, n *yaml.Node) (int, bool) {
+	offset := 0
+	for line := 1; line < n.Line; line++ {
+		i := bytes.IndexByte(data[offset:], '\n')
+		if i < 0 {
+			return 0, false
+		}
+		offset += i + 1
+	}
+	for column := 1; column < n.Column; column++ {
+		if offset >= len(data) || data[offset] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRune(data[offset:])
+		offset += size
+	}
+	return offset, true
+}
+
+// scalarEnd returns the offset right after the scalar n starting at start.
+// Only single line scalars are supported.
+func scalarEnd(data []byte, start int, n *yaml.Node) (int, bool) {
+	switch n.Style {
+	case 0:
+		if strings.Contains(n.Value, "\n") || !bytes.HasPrefix(data[start:], []byte(n.Value)) {
+			return 0, false
+		}
+		return start + len(n.Value), true
+	case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+		quote := data[start]
+		for i := start + 1; i < len(data); i++ {
+			switch {
+			case data[i] == '\n':
+				return 0, false
+			case quote == '"' && data[i] == '\\':
+				i++
+			case quote == '\'' && data[i] == '\'' && i+1 < len(data) && data[i+1] == '\'':
+				i++
+			case data[i] == quote:
+				return i + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// formatScalar writes value as a YAML scalar in the given style. Plain
+// values are quoted when they would otherwise read as something else.
+func formatScalar(value string, style yaml.Style) (string, bool) {
+	switch style {
+	case 0:
+		out, err := yaml.Marshal(value)
+		if err != nil || strings.Count(string(out), "\n") != 1 {
+			return "", false
+		}
+		return strings.TrimSuffix(string(out), "\n"), true
+	case yaml.DoubleQuotedStyle:
+		// JSON strings are valid double quoted YAML, and keep JSON files JSON
+		out, err := json.Marshal(value)
+		return string(out), err == nil
+	case yaml.SingleQuotedStyle:
+		if strings.Contains(value, "\n") {
+			return "", false
+		}
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'", true
+	}
+	return "", false
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const handWrittenKubeConfig = `# Managed in my dotfiles
+apiVersion: v1
+kind: Config
+current-context: "staging"   # the usual one
+contexts:
+  - name: staging
+    context:
+      cluster: staging
+      user: me
+      namespace: web
+  - name: 'prod'
+    context:
+      cluster: prod
+      user: me # read-only
+clusters:
+  - name: staging
+    cluster: {server: "https://staging.example.com"}
+  - name: prod
+    cluster: {server: "https://prod.example.com"}
+users:
+  - name: me
+    user:
+      exec:
+        apiVersion: client.authentication.k8s.io/v1
+        command: get-token
+`
+
+func TestPatchKubeConfig_OnlyChangesValues(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{PatchKubeConfig: true})
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(handWrittenKubeConfig), 0o640))
+	setKubeConfigPath(t, cfgPath)
+
+	require.NoError(t, applyFavorite(loadRawConfig(t), "prod", "payments"))
+	require.NoError(t, applyNamespaceChange(loadRawConfig(t), "staging", "api"))
+
+	data, err := os.ReadFile(cfgPath)
+	require.NoError(t, err)
+	expected := strings.NewReplacer(
+		`current-context: "staging"`, `current-context: "prod"`,
+		"namespace: web", "namespace: api",
+		"      user: me # read-only\n", "      namespace: payments\n      user: me # read-only\n",
+	).Replace(handWrittenKubeConfig)
+	assert.Equal(t, expected, string(data))
+
+	info, err := os.Stat(cfgPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	assert.Len(t, readJournal(), 2)
+}
+
+func TestPatchKubeConfig_WritesThroughSymlink(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{PatchKubeConfig: true})
+	dotfiles := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(dotfiles, []byte(handWrittenKubeConfig), 0o600))
+	link := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.Symlink(dotfiles, link))
+	setKubeConfigPath(t, link)
+
+	require.NoError(t, applyContextChange(loadRawConfig(t), "prod"))
+
+	target, err := os.Readlink(link)
+	require.NoError(t, err, "the link is kept")
+	assert.Equal(t, dotfiles, target)
+	data, err := os.ReadFile(dotfiles)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(handWrittenKubeConfig, `current-context: "staging"`, `current-context: "prod"`, 1), string(data))
+}
+
+func TestPatchKubeConfig_WritesInPlace(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{PatchKubeConfig: true})
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(handWrittenKubeConfig), 0o600))
+	hardLink := filepath.Join(dir, "hard-link")
+	require.NoError(t, os.Link(cfgPath, hardLink))
+	setKubeConfigPath(t, cfgPath)
+
+	require.NoError(t, applyContextChange(loadRawConfig(t), "prod"))
+
+	data, err := os.ReadFile(hardLink)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `current-context: "prod"`)
+}
+
+func TestPatchKubeConfigData(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		changes  []fieldChange
+		expected string
+	}{
+		{
+			name:     "plain value quoted when needed",
+			data:     "apiVersion: v1\ncurrent-context: a\nkind: Config\n",
+			changes:  []fieldChange{{Field: fieldCurrentContext, To: "on"}},
+			expected: "apiVersion: v1\ncurrent-context: \"on\"\nkind: Config\n",
+		},
+		{
+			name:     "single quoted",
+			data:     "current-context: 'a'\n",
+			changes:  []fieldChange{{Field: fieldCurrentContext, To: "it's"}},
+			expected: "current-context: 'it''s'\n",
+		},
+		{
+			name:     "missing current-context added before kind",
+			data:     "apiVersion: v1\nkind: Config\n",
+			changes:  []fieldChange{{Field: fieldCurrentContext, To: "a"}},
+			expected: "apiVersion: v1\ncurrent-context: a\nkind: Config\n",
+		},
+		{
+			name:     "json",
+			data:     `{"current-context": "a", "contexts": [{"name": "a", "context": {"cluster": "a", "namespace": "x"}}]}`,
+			changes:  []fieldChange{{Field: fieldCurrentContext, To: "b"}, {Field: fieldNamespace, Context: "a", To: "y"}},
+			expected: `{"current-context": "b", "contexts": [{"name": "a", "context": {"cluster": "a", "namespace": "y"}}]}`,
+		},
+		{
+			name:     "namespace removed",
+			data:     "contexts:\n- context:\n    cluster: a\n    namespace: x\n  name: a\n",
+			changes:  []fieldChange{{Field: fieldNamespace, Context: "a", To: ""}},
+			expected: "contexts:\n- context:\n    cluster: a\n    namespace: \"\"\n  name: a\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := patchKubeConfigData([]byte(tt.data), tt.changes)
+			require.True(t, ok)
+			assert.Equal(t, tt.expected, string(result))
+		})
+	}
+}
+
+func TestPatchKubeConfigData_CantPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		changes []fieldChange
+	}{
+		{"flow mapping without the key", `{"kind": "Config"}`, []fieldChange{{Field: fieldCurrentContext, To: "a"}}},
+		{"multi-line value", "current-context: >\n  a\n", []fieldChange{{Field: fieldCurrentContext, To: "b"}}},
+		{"unknown context", "contexts: []\n", []fieldChange{{Field: fieldNamespace, Context: "a", To: "x"}}},
+		{"anchor", "current-context: &c a\n", []fieldChange{{Field: fieldCurrentContext, To: "b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := patchKubeConfigData([]byte(tt.data), tt.changes)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestPatchKubeConfig_FallsBackToRewrite(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{PatchKubeConfig: true})
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`{"kind": "Config", "contexts": [{"name": "a", "context": {"cluster": "a"}}]}`), 0o600))
+	setKubeConfigPath(t, cfgPath)
+
+	require.NoError(t, applyContextChange(loadRawConfig(t), "a"))
+
+	assert.Equal(t, "a", loadRawConfig(t).CurrentContext)
+	assert.Len(t, readJournal(), 1)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+
+	prompt "github.com/c-bata/go-prompt"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// previewSeparator separates the details shown next to a suggestion.
+const previewSeparator = " · "
+
+// contextPreview describes contextName for the context prompt: the host of
+// its cluster's server, its user, default namespace and how the user
+// authenticates, e.g. "eks.example.com · user admin · ns payments · exec".
+// Details the kubeconfig doesn't have are left out.
+func contextPreview(rawConfig api.Config, contextName string) string {
+	ctx := rawConfig.Contexts[contextName]
+	if ctx == nil {
+		return ""
+	}
+
+	details := []string{}
+	if host := serverHost(rawConfig, contextName); host != "" {
+		details = append(details, host)
+	}
+	if ctx.AuthInfo != "" {
+		details = append(details, "user "+ctx.AuthInfo)
+	}
+	if ctx.Namespace != "" {
+		details = append(details, "ns "+ctx.Namespace)
+	}
+	if auth := authType(rawConfig.AuthInfos[ctx.AuthInfo]); auth != "" {
+		details = append(details, auth)
+	}
+	return strings.Join(details, previewSeparator)
+}
+
+// authType tells how authInfo authenticates: "exec", "token", "cert",
+// "basic" or the name of its auth provider, e.g. "oidc". It's "" when there
+// are no credentials.
+func authType(authInfo *api.AuthInfo) string {
+	switch {
+	case authInfo == nil:
+		return ""
+	case authInfo.Exec != nil:
+		return "exec"
+	case authInfo.AuthProvider != nil:
+		return authInfo.AuthProvider.Name
+	case authInfo.Token != "" || authInfo.TokenFile != "":
+		return "token"
+	case len(authInfo.ClientCertificateData) > 0 || authInfo.ClientCertificate != "":
+		return "cert"
+	case authInfo.Username != "":
+		return "basic"
+	}
+	return ""
+}
+
+// filterSuggestions keeps the suggestions fuzzy matching sub. With
+// matchDetails, what's shown next to a suggestion is matched as well, so
+// e.g. "eu-west" finds every context on a cluster in that region.
+func filterSuggestions(suggestions []prompt.Suggest, sub string, matchDetails bool) []prompt.Suggest {
+	if !matchDetails {
+		return prompt.FilterFuzzy(suggestions, sub, true)
+	}
+
+	matches := []prompt.Suggest{}
+	for _, s := range suggestions {
+		candidates := []prompt.Suggest{{Text: s.Text}, {Text: s.Description}}
+		if len(prompt.FilterFuzzy(candidates, sub, true)) > 0 {
+			matches = append(matches, s)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	prompt "github.com/c-bata/go-prompt"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestContextPreview(t *testing.T) {
+	rawConfig := api.Config{
+		Clusters: map[string]*api.Cluster{
+			"eks": {Server: "https://abc.gr7.eu-west-1.eks.amazonaws.com"},
+		},
+		AuthInfos: map[string]*api.AuthInfo{
+			"admin": {Exec: &api.ExecConfig{Command: "aws"}},
+			"ci":    {Token: "secret"},
+			"me":    {ClientCertificateData: []byte("cert")},
+			"sso":   {AuthProvider: &api.AuthProviderConfig{Name: "oidc"}},
+		},
+		Contexts: map[string]*api.Context{
+			"payments": {Cluster: "eks", AuthInfo: "admin", Namespace: "payments"},
+			"ci":       {Cluster: "eks", AuthInfo: "ci"},
+			"local":    {Cluster: "missing", AuthInfo: "me"},
+			"sso":      {AuthInfo: "sso", Namespace: "web"},
+			"bare":     {},
+		},
+	}
+
+	assert.Equal(t, "abc.gr7.eu-west-1.eks.amazonaws.com · user admin · ns payments · exec", contextPreview(rawConfig, "payments"))
+	assert.Equal(t, "abc.gr7.eu-west-1.eks.amazonaws.com · user ci · token", contextPreview(rawConfig, "ci"))
+	assert.Equal(t, "user me · cert", contextPreview(rawConfig, "local"))
+	assert.Equal(t, "user sso · ns web · oidc", contextPreview(rawConfig, "sso"))
+	assert.Empty(t, contextPreview(rawConfig, "bare"))
+	assert.Empty(t, contextPreview(rawConfig, "unknown"))
+}
+
+func TestFilterSuggestions_MatchDetails(t *testing.T) {
+	suggestions := []prompt.Suggest{
+		{Text: "payments", Description: "abc.gr7.eu-west-1.eks.amazonaws.com · user admin"},
+		{Text: "orders", Description: "def.gr7.eu-west-1.eks.amazonaws.com · user admin"},
+		{Text: "local", Description: "127.0.0.1 · user me"},
+	}
+
+	assert.Empty(t, filterSuggestions(suggestions, "eu-west", false))
+	assert.Equal(t, suggestions[:2], filterSuggestions(suggestions, "eu-west", true))
+	assert.Equal(t, suggestions[2:], filterSuggestions(suggestions, "loc", true))
+	assert.Equal(t, suggestions, filterSuggestions(suggestions, "", true))
+}
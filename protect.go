@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// protectedTag marks a favorite whose context needs confirmation.
+	protectedTag = "protected"
+
+	confirmYes  = "yes"
+	confirmName = "name"
+)
+
+// assumeYes is set by -y and skips confirmation for protected contexts.
+var assumeYes bool
+
+// isProtected reports whether contextName matches one of the protected
+// patterns in the config, or is used by a favorite tagged "protected".
+func isProtected(contextName string) (bool, error) {
+	if matchesAny(skCfg.Protected, contextName) {
+		return true, nil
+	}
+	store, err := loadFavorites()
+	if err != nil {
+		return false, err
+	}
+	for _, f := range store.Favorites {
+		if f.Context == contextName && slices.Contains(f.Tags, protectedTag) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// confirmSwitch asks for confirmation before a protected context becomes
+// the current one. Staying in the current context never needs it, and without
+// a terminal to ask on the switch is refused unless -y was given.
+func confirmSwitch(rawConfig api.Config, contextName string) error {
+	if assumeYes || contextName == rawConfig.CurrentContext {
+		return nil
+	}
+	protected, err := isProtected(contextName)
+	if err != nil || !protected {
+		return err
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("context %q is protected, pass -y to switch to it", contextName)
+	}
+	// A prompt shown before may have left the terminal in raw mode
+	restoreTermState()
+	if !askConfirmation(os.Stdin, os.Stderr, contextName, skCfg.ProtectedConfirm) {
+		return fmt.Errorf("not switching to protected context %q", contextName)
+	}
+	return nil
+}
+
+// askConfirmation asks on out and reads the answer from in. With mode "name"
+// the context name has to be typed, otherwise "y" or "yes" confirms.
+func askConfirmation(in io.Reader, out io.Writer, contextName, mode string) bool {
+	if mode == confirmName {
+		fmt.Fprintf(out, "%q is a protected context. Type its name to switch to it: ", contextName)
+	} else {
+		fmt.Fprintf(out, "%q is a protected context. Switch to it? [y/N] ", contextName)
+	}
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false
+	}
+	answer = strings.TrimSpace(answer)
+	if mode == confirmName {
+		return answer == contextName
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setSkConfig replaces the loaded sk config for the duration of the test.
+func setSkConfig(t *testing.T, c skConfig) {
+	t.Helper()
+	orig := skCfg
+	skCfg = c
+	t.Cleanup(func() { skCfg = orig })
+}
+
+func TestIsProtected(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{Protected: []string{"*prod*"}})
+	require.NoError(t, saveFavorite("billing", favorite{Context: "billing-live", Tags: []string{"protected"}}, false))
+	require.NoError(t, saveFavorite("scratch", favorite{Context: "scratch", Tags: []string{"dev"}}, false))
+
+	for name, expected := range map[string]bool{"eu-prod-1": true, "billing-live": true, "staging": false, "scratch": false} {
+		protected, err := isProtected(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, protected, name)
+	}
+}
+
+func TestIsProtected_WithoutStateDir(t *testing.T) {
+	orig := skDir
+	skDir = filepath.Join(t.TempDir(), "missing")
+	t.Cleanup(func() { skDir = orig })
+	setSkConfig(t, skConfig{})
+
+	protected, err := isProtected("prod")
+	require.NoError(t, err)
+	assert.False(t, protected)
+}
+
+func TestAskConfirmation(t *testing.T) {
+	tests := []struct {
+		mode   string
+		answer string
+		want   bool
+	}{
+		{confirmYes, "y\n", true},
+		{confirmYes, "YES\n", true},
+		{confirmYes, "\n", false},
+		{confirmYes, "", false},
+		{"", "y\n", true},
+		{confirmName, "prod-eu\n", true},
+		{confirmName, "y\n", false},
+		{confirmName, "prod-e\n", false},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		got := askConfirmation(strings.NewReader(tt.answer), &out, "prod-eu", tt.mode)
+		assert.Equal(t, tt.want, got, "mode %q, answer %q", tt.mode, tt.answer)
+		assert.Contains(t, out.String(), `"prod-eu" is a protected context`)
+	}
+}
+
+func TestApplyContextChange_ProtectedNeedsConfirmation(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{Protected: []string{"prod-*"}})
+	cfgPath := writeKubeConfigFile(t, t.TempDir(), "config", "staging", "staging", "prod-eu")
+	setKubeConfigPath(t, cfgPath)
+	before, err := os.ReadFile(cfgPath)
+	require.NoError(t, err)
+
+	// There is no terminal to ask on in tests
+	err = applyContextChange(loadRawConfig(t), "prod-eu")
+	assert.EqualError(t, err, `context "prod-eu" is protected, pass -y to switch to it`)
+	err = applyFavorite(loadRawConfig(t), "prod-eu", "payments")
+	assert.Error(t, err)
+	after, err := os.ReadFile(cfgPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+
+	assumeYes = true
+	t.Cleanup(func() { assumeYes = false })
+	require.NoError(t, applyContextChange(loadRawConfig(t), "prod-eu"))
+	assert.Equal(t, "prod-eu", loadRawConfig(t).CurrentContext)
+
+	// Staying in the protected context doesn't ask again
+	assumeYes = false
+	require.NoError(t, applyFavorite(loadRawConfig(t), "prod-eu", "payments"))
+}
+
+func TestReadConfig_RejectsInvalidProtectedSettings(t *testing.T) {
+	writeConfig(t, "protected: [\"[prod\"]\n")
+	_, err := readConfig()
+	assert.ErrorContains(t, err, `protected pattern "[prod"`)
+
+	writeConfig(t, "protectedConfirm: maybe\n")
+	_, err = readConfig()
+	assert.ErrorContains(t, err, `protectedConfirm must be "yes" or "name", not "maybe"`)
+}
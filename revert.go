@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/yaml"
+)
+
+// revertStateFile holds a pending switch back, scheduled with -for or by
+// protectedExpiry.
+const revertStateFile = "revert.yaml"
+
+// revertAfter is set by -for: switch back to where sk came from after this
+// long.
+var revertAfter time.Duration
+
+// revertState is a pending switch back to Context and Namespace, due At,
+// that only happens if the current context and namespace are still the
+// Pinned ones sk switched to.
+type revertState struct {
+	Context         string    `json:"context"`
+	Namespace       string    `json:"namespace"`
+	PinnedContext   string    `json:"pinnedContext"`
+	PinnedNamespace string    `json:"pinnedNamespace"`
+	At              time.Time `json:"at"`
+}
+
+func revertStatePath() string {
+	return path.Join(skDir, sessionStateKey(revertStateFile))
+}
+
+func readRevertState() (revertState, bool) {
+	data, err := os.ReadFile(revertStatePath())
+	if err != nil {
+		return revertState{}, false
+	}
+	r := revertState{}
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return revertState{}, false
+	}
+	return r, true
+}
+
+// updateRevert is called after every switch. A switch with -for, or to a
+// protected context when protectedExpiry is set, schedules a switch back and
+// returns it; any other switch cancels a pending one. Moving on from a
+// context that is about to be switched back from keeps the place to go back
+// to.
+func updateRevert(fromContext, fromNamespace, toContext, toNamespace string) (*revertState, error) {
+	after := revertAfter
+	if after == 0 {
+		protected, err := isProtected(toContext)
+		if err != nil {
+			return nil, err
+		}
+		if protected {
+			after = skCfg.ProtectedExpiry.Duration
+		}
+	}
+
+	var scheduled *revertState
+	err := withStateLock(func() error {
+		if after == 0 {
+			err := os.Remove(revertStatePath())
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		r := revertState{Context: fromContext, Namespace: fromNamespace}
+		if pending, ok := readRevertState(); ok && pending.PinnedContext == fromContext && pending.PinnedNamespace == fromNamespace {
+			r.Context, r.Namespace = pending.Context, pending.Namespace
+		}
+		r.PinnedContext, r.PinnedNamespace = toContext, toNamespace
+		r.At = time.Now().Add(after).UTC()
+		data, err := yaml.Marshal(r)
+		if err != nil {
+			return err
+		}
+		scheduled = &r
+		return writeFileAtomic(revertStatePath(), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+// checkRevert carries out a pending switch back once it is due. Besides the
+// helper started by startRevertHelper, it is called on every run of sk,
+// including the prompt segment, in case the helper didn't survive.
+func checkRevert() error {
+	r, ok := readRevertState()
+	if !ok || time.Now().Before(r.At) {
+		return nil
+	}
+	// Unless it was rescheduled in the meantime, it's done after this
+	err := withStateLock(func() error {
+		if current, ok := readRevertState(); ok && current.At.Equal(r.At) {
+			if err := os.Remove(revertStatePath()); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switched := false
+	err = updateKubeConfig(func(c *api.Config) error {
+		currentContext, currentNamespace := currentContextAndNamespace(*c)
+		if currentContext != r.PinnedContext || currentNamespace != r.PinnedNamespace {
+			// Switched elsewhere since, leave it alone
+			return nil
+		}
+		switched = true
+		return selectContextAndNamespace(c, r.Context, r.Namespace)
+	})
+	if err != nil {
+		return err
+	}
+	if switched {
+		fmt.Fprintf(os.Stderr, "Switched back from %s/%s to %s/%s\n", r.PinnedContext, r.PinnedNamespace, r.Context, r.Namespace)
+	}
+	return nil
+}
+
+// startRevertHelper starts "sk __revert" in the background, detached from
+// the terminal, to carry out the switch back on time.
+func startRevertHelper() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, "__revert")
+	// A session started by this run isn't in the environment yet
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeConfigPath)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// runRevertHelper implements the hidden "sk __revert": it waits until the
+// pending switch back is due and carries it out. When the switch back was
+// rescheduled or cancelled in the meantime, checkRevert does nothing.
+func runRevertHelper() error {
+	r, ok := readRevertState()
+	if !ok {
+		return nil
+	}
+	time.Sleep(time.Until(r.At))
+	return checkRevert()
+}
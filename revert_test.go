@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+// expireRevert makes the pending switch back due.
+func expireRevert(t *testing.T) {
+	t.Helper()
+	r, ok := readRevertState()
+	require.True(t, ok)
+	r.At = time.Now().Add(-time.Second)
+	data, err := yaml.Marshal(r)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(revertStatePath(), data, 0o600))
+}
+
+func TestRevert_SwitchesBackOnceDue(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{Protected: []string{"prod-*"}, ProtectedExpiry: duration{time.Minute}})
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "prod-eu", "staging", "prod-eu", "prod-us"))
+
+	// staging -> prod-eu -> prod-us still goes back to staging
+	_, err := updateRevert("staging", "default", "prod-eu", "default")
+	require.NoError(t, err)
+	_, err = updateRevert("prod-eu", "default", "prod-us", "default")
+	require.NoError(t, err)
+	r, ok := readRevertState()
+	require.True(t, ok)
+	assert.Equal(t, "staging", r.Context)
+	assert.Equal(t, "prod-us", r.PinnedContext)
+
+	// Not due yet
+	_, err = updateRevert("staging", "default", "prod-eu", "default")
+	require.NoError(t, err)
+	require.NoError(t, checkRevert())
+	assert.Equal(t, "prod-eu", loadRawConfig(t).CurrentContext)
+
+	expireRevert(t)
+	require.NoError(t, checkRevert())
+	assert.Equal(t, "staging", loadRawConfig(t).CurrentContext)
+	_, ok = readRevertState()
+	assert.False(t, ok)
+}
+
+func TestRevert_LeavesOtherSwitchesAlone(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{Protected: []string{"prod-*"}, ProtectedExpiry: duration{time.Minute}})
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "other", "staging", "prod-eu", "other"))
+
+	_, err := updateRevert("staging", "default", "prod-eu", "default")
+	require.NoError(t, err)
+	expireRevert(t)
+
+	// The current context was changed by something else in the meantime
+	require.NoError(t, checkRevert())
+	assert.Equal(t, "other", loadRawConfig(t).CurrentContext)
+
+	// Switching to an unprotected context cancels a pending revert
+	_, err = updateRevert("staging", "default", "prod-eu", "default")
+	require.NoError(t, err)
+	_, err = updateRevert("prod-eu", "default", "staging", "default")
+	require.NoError(t, err)
+	_, ok := readRevertState()
+	assert.False(t, ok)
+}
+
+func TestRevert_ForSwitchesBackFromAnyContext(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{})
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "staging", "dev", "staging"))
+	revertAfter = 15 * time.Minute
+	t.Cleanup(func() { revertAfter = 0 })
+
+	start := time.Now()
+	r, err := updateRevert("dev", "default", "staging", "default")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "dev", r.Context)
+	assert.WithinDuration(t, start.Add(15*time.Minute), r.At, time.Minute)
+
+	expireRevert(t)
+	require.NoError(t, checkRevert())
+	assert.Equal(t, "dev", loadRawConfig(t).CurrentContext)
+}
+
+func TestRunRevertHelper_WaitsUntilDue(t *testing.T) {
+	setSkDir(t)
+	setSkConfig(t, skConfig{})
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "staging", "dev", "staging"))
+	revertAfter = 200 * time.Millisecond
+	t.Cleanup(func() { revertAfter = 0 })
+
+	start := time.Now()
+	_, err := updateRevert("dev", "default", "staging", "default")
+	require.NoError(t, err)
+	require.NoError(t, runRevertHelper())
+
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Equal(t, "dev", loadRawConfig(t).CurrentContext)
+}
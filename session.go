@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// A session is a small kubeconfig under ~/.sk/sessions that is put first in
+// $KUBECONFIG for a single shell. It only holds current-context and copies of
+// the contexts whose namespace was changed in that shell, so everything else
+// keeps coming from the user's own files, which sk then never writes to.
+const sessionsDirName = "sessions"
+
+// sessionMaxAge is how long a session is kept after the last switch in it.
+// Older ones are removed when a new session starts.
+const sessionMaxAge = 30 * 24 * time.Hour
+
+var (
+	// sessionRequested is set by -s or $SK_SESSION; the first change then
+	// starts a new session instead of writing to the shared kubeconfig.
+	sessionRequested = os.Getenv("SK_SESSION") != ""
+	// sessionStarted is set once this invocation created a session, meaning
+	// the caller needs to be told about the new $KUBECONFIG.
+	sessionStarted bool
+)
+
+func sessionsDir() string {
+	return path.Join(skDir, sessionsDirName)
+}
+
+// activeSession returns the session file of the current shell, or "" when
+// $KUBECONFIG doesn't start with one.
+func activeSession() string {
+	paths := splitKubeConfigPath(kubeConfigPath)
+	if len(paths) < 2 {
+		return ""
+	}
+	if filepath.Dir(filepath.Clean(paths[0])) != filepath.Clean(sessionsDir()) {
+		return ""
+	}
+	return paths[0]
+}
+
+// startSession creates an empty session file and puts it in front of the
+// current kubeconfig path.
+func startSession() error {
+	if err := os.MkdirAll(sessionsDir(), 0o700); err != nil {
+		return err
+	}
+	pruneSessions(time.Now())
+
+	f, err := os.CreateTemp(sessionsDir(), "*.yaml")
+	if err != nil {
+		return err
+	}
+	sessionFile := f.Name()
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = clientcmd.WriteToFile(*api.NewConfig(), sessionFile); err != nil {
+		os.Remove(sessionFile)
+		return err
+	}
+
+	kubeConfigPath = strings.Join(append([]string{sessionFile}, splitKubeConfigPath(kubeConfigPath)...), string(filepath.ListSeparator))
+	sessionStarted = true
+	return nil
+}
+
+// pruneSessions removes the sessions that haven't been switched in for
+// sessionMaxAge, along with their state files, and state files of sessions
+// that no longer exist. Best effort, as it only saves space.
+func pruneSessions(now time.Time) {
+	entries, err := os.ReadDir(sessionsDir())
+	if err != nil {
+		return
+	}
+
+	lastUsed := map[string]time.Time{}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".yaml")
+		if !ok || sessionID(e.Name()) != id {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			lastUsed[id] = info.ModTime()
+		}
+	}
+	for _, e := range entries {
+		if used, ok := lastUsed[sessionID(e.Name())]; !ok || now.Sub(used) > sessionMaxAge {
+			_ = os.Remove(path.Join(sessionsDir(), e.Name()))
+		}
+	}
+}
+
+// sessionID returns the session a file in the sessions dir belongs to: the
+// session file "<id>.yaml" or a state file "<id>_<key>".
+func sessionID(fileName string) string {
+	id, _, _ := strings.Cut(fileName, "_")
+	id, _, _ = strings.Cut(id, ".")
+	return id
+}
+
+// writeSession stores the parts of c that differ from the shared kubeconfig in
+// the session file: current-context, plus every context whose namespace was
+// changed now or earlier in the session. The overlay is written the same way.
+func writeSession(sessionFile string, c api.Config) error {
+	base, err := newLoadingRules(splitKubeConfigPath(kubeConfigPath)[1:]).Load()
+	if err != nil {
+		return err
+	}
+	existing, err := clientcmd.LoadFromFile(sessionFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if existing == nil {
+		existing = api.NewConfig()
+	}
+
+	session := api.NewConfig()
+	session.CurrentContext = c.CurrentContext
+	for name, ctx := range c.Contexts {
+		baseCtx := base.Contexts[name]
+		_, inSession := existing.Contexts[name]
+		if inSession || baseCtx == nil || baseCtx.Namespace != ctx.Namespace {
+			sessionCtx := *ctx
+			sessionCtx.LocationOfOrigin = ""
+			session.Contexts[name] = &sessionCtx
+		}
+	}
+
+	data, err := clientcmd.Write(*session)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(sessionFile, data)
+}
+
+// sessionExport is the line a shell has to eval to use the current session.
+func sessionExport() string {
+	return fmt.Sprintf("export KUBECONFIG=%s", shellQuote(kubeConfigPath))
+}
+
+// exportFdEnv names the file descriptor the "sk init" wrappers read the new
+// $KUBECONFIG from. Only sk writes to it, so nothing else sk prints, such as
+// a context name, is ever taken for the export line.
+const exportFdEnv = "SK_EXPORT_FD"
+
+// printSessionExport tells the calling shell about the new $KUBECONFIG: on
+// the file descriptor in $SK_EXPORT_FD when a wrapper passed one, or as the
+// line to eval on stdout otherwise.
+func printSessionExport() {
+	if fd, err := strconv.Atoi(os.Getenv(exportFdEnv)); err == nil && fd > 2 {
+		f := os.NewFile(uintptr(fd), exportFdEnv)
+		_, err := f.WriteString(kubeConfigPath)
+		f.Close()
+		if err == nil {
+			return
+		}
+	}
+	fmt.Println(sessionExport())
+}
+
+// shellQuote quotes s for POSIX shells and fish.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
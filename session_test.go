@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// setSkDir points sk's state at a fresh temp dir for the duration of the test.
+func setSkDir(t *testing.T) string {
+	t.Helper()
+	orig := skDir
+	skDir = filepath.Join(t.TempDir(), ".sk")
+	require.NoError(t, createSkDir())
+	t.Cleanup(func() { skDir = orig })
+	return skDir
+}
+
+func requestSession(t *testing.T) {
+	t.Helper()
+	origRequested, origStarted := sessionRequested, sessionStarted
+	sessionRequested = true
+	t.Cleanup(func() { sessionRequested, sessionStarted = origRequested, origStarted })
+}
+
+func TestSession_LeavesSharedKubeConfigUntouched(t *testing.T) {
+	setSkDir(t)
+	shared := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, shared)
+	requestSession(t)
+	before, err := os.ReadFile(shared)
+	require.NoError(t, err)
+
+	cfg, err := loadConfig().RawConfig()
+	require.NoError(t, err)
+	require.NoError(t, applyContextChange(cfg, "ctx-b"))
+	cfg, err = loadConfig().RawConfig()
+	require.NoError(t, err)
+	require.NoError(t, applyNamespaceChange(cfg, "ctx-b", "payments"))
+
+	after, err := os.ReadFile(shared)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+
+	session := activeSession()
+	require.NotEmpty(t, session)
+	assert.True(t, sessionStarted)
+	assert.Equal(t, sessionsDir(), filepath.Dir(session))
+	assert.Equal(t, "export KUBECONFIG='"+session+string(filepath.ListSeparator)+shared+"'", sessionExport())
+
+	// The merged view reflects the session...
+	cfg, err = loadConfig().RawConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "ctx-b", cfg.CurrentContext)
+	assert.Equal(t, "payments", cfg.Contexts["ctx-b"].Namespace)
+	assert.Equal(t, "ctx-b", cfg.Contexts["ctx-b"].Cluster)
+
+	// ...while the session file only holds the override.
+	sessionCfg, err := clientcmd.LoadFromFile(session)
+	require.NoError(t, err)
+	assert.Equal(t, "ctx-b", sessionCfg.CurrentContext)
+	assert.Len(t, sessionCfg.Contexts, 1)
+	assert.Empty(t, sessionCfg.Clusters)
+	assert.Empty(t, sessionCfg.AuthInfos)
+}
+
+func TestSession_PreviousStateIsPerSession(t *testing.T) {
+	setSkDir(t)
+	shared := writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a", "ctx-b")
+	setKubeConfigPath(t, shared)
+
+	require.NoError(t, storePreviousState("ctx-a", "global"))
+
+	requestSession(t)
+	require.NoError(t, startSession())
+	ctx, ns := readPreviousState()
+	assert.Empty(t, ctx)
+	assert.Empty(t, ns)
+
+	require.NoError(t, storePreviousState("ctx-b", "session"))
+	ctx, ns = readPreviousState()
+	assert.Equal(t, "ctx-b", ctx)
+	assert.Equal(t, "session", ns)
+	assert.True(t, strings.HasPrefix(previousStateKey(), sessionsDirName+"/"))
+
+	setKubeConfigPath(t, shared)
+	ctx, ns = readPreviousState()
+	assert.Equal(t, "ctx-a", ctx)
+	assert.Equal(t, "global", ns)
+}
+
+func TestStartSession_PrunesOldSessions(t *testing.T) {
+	setSkDir(t)
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "ctx-a", "ctx-a"))
+	requestSession(t)
+	require.NoError(t, os.MkdirAll(sessionsDir(), 0o700))
+	old := time.Now().Add(-sessionMaxAge - time.Hour)
+	for _, name := range []string{"1.yaml", "1_previous_state", "1_revert.yaml", "2.yaml", "2_previous_state", "3_previous_state"} {
+		require.NoError(t, os.WriteFile(filepath.Join(sessionsDir(), name), nil, 0o600))
+	}
+	require.NoError(t, os.Chtimes(filepath.Join(sessionsDir(), "1.yaml"), old, old))
+
+	require.NoError(t, startSession())
+
+	entries, err := os.ReadDir(sessionsDir())
+	require.NoError(t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"2.yaml", "2_previous_state", filepath.Base(activeSession())}, names)
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'/a b/c'`, shellQuote("/a b/c"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+}
+
+func TestPrintSessionExport_WritesToExportFd(t *testing.T) {
+	if kubeConfig := os.Getenv("SK_TEST_EXPORT"); kubeConfig != "" {
+		// Running as the helper process below
+		kubeConfigPath = kubeConfig
+		printSessionExport()
+		return
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("needs file descriptors passed on")
+	}
+	kubeConfig := "/tmp/sk session.yaml" + string(filepath.ListSeparator) + "/home/me/.kube/config"
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPrintSessionExport_WritesToExportFd$")
+	cmd.Env = append(os.Environ(), "SK_TEST_EXPORT="+kubeConfig, exportFdEnv+"=3")
+	cmd.ExtraFiles = []*os.File{w}
+	stdout, err := cmd.Output()
+	w.Close()
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, kubeConfig, string(data))
+	assert.NotContains(t, string(stdout), "KUBECONFIG")
+
+	// Without the wrapper, the line to eval is printed
+	t.Setenv(exportFdEnv, "")
+	setKubeConfigPath(t, kubeConfig)
+	assert.Equal(t, sessionExport()+"\n", captureStdout(t, printSessionExport))
+}
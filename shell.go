@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shellInitScripts hold what "sk init <shell>" prints. Each one wraps sk in a
+// shell function that sets $KUBECONFIG when a session starts, except for "sk
+// exec" and "sk shell", which run commands of their own. It also adds a
+// "(⎈ context/namespace)" prompt segment unless $SK_NO_PROMPT is set, and
+// registers tab completion backed by "sk __complete".
+var shellInitScripts = map[string]string{
+	"bash": bashInit,
+	"zsh":  zshInit,
+	"fish": fishInit,
+}
+
+const bashInit = `# sk shell integration. Add to ~/.bashrc:
+#   eval "$(sk init bash)"
+sk() {
+  local sk_kubeconfig sk_status
+  case "$1" in
+    exec|shell) command sk "$@"; return ;;
+  esac
+  # sk writes the new KUBECONFIG to fd 3, its output goes straight through
+  {
+    sk_kubeconfig="$(SK_EXPORT_FD=3 command sk "$@" 3>&1 1>&4 4>&-)"
+    sk_status=$?
+  } 4>&1
+  [ -z "$sk_kubeconfig" ] || export KUBECONFIG="$sk_kubeconfig"
+  return $sk_status
+}
+
+__sk_ps1() {
+  local sk_current
+  sk_current="$(command sk __prompt 2>/dev/null)"
+  [ -z "$sk_current" ] || printf '(⎈ %s) ' "$sk_current"
+}
+
+if [ -z "${SK_NO_PROMPT-}" ]; then
+  case "$PS1" in
+    *__sk_ps1*) ;;
+    *) PS1='$(__sk_ps1)'"$PS1" ;;
+  esac
+fi
+
+__sk_complete() {
+  local IFS=$'\n' sk_line sk_words sk_cur sk_prefix
+  # COMP_WORDS is split on ":" too, which EKS context names are full of
+  sk_line="${COMP_LINE:0:COMP_POINT}"
+  IFS=$' \t\n' read -r -a sk_words <<< "$sk_line"
+  case "$sk_line" in
+    *[[:space:]]) sk_words+=("") ;;
+  esac
+  sk_cur="${sk_words[${#sk_words[@]}-1]}"
+  COMPREPLY=($(command sk __complete "${sk_words[@]}" 2>/dev/null))
+  # Bash only replaces what follows the last ":" of the word
+  if [[ "$sk_cur" == *:* && "$COMP_WORDBREAKS" == *:* ]]; then
+    sk_prefix="${sk_cur%"${sk_cur##*:}"}"
+    COMPREPLY=("${COMPREPLY[@]#"$sk_prefix"}")
+  fi
+}
+complete -o nosort -F __sk_complete sk 2>/dev/null || complete -F __sk_complete sk
+`
+
+const zshInit = `# sk shell integration. Add to ~/.zshrc (after compinit):
+#   eval "$(sk init zsh)"
+sk() {
+  local sk_kubeconfig sk_status
+  case "$1" in
+    exec|shell) command sk "$@"; return ;;
+  esac
+  # sk writes the new KUBECONFIG to fd 3, its output goes straight through
+  {
+    sk_kubeconfig="$(SK_EXPORT_FD=3 command sk "$@" 3>&1 1>&4 4>&-)"
+    sk_status=$?
+  } 4>&1
+  [ -z "$sk_kubeconfig" ] || export KUBECONFIG="$sk_kubeconfig"
+  return $sk_status
+}
+
+__sk_ps1() {
+  local sk_current
+  sk_current="$(command sk __prompt 2>/dev/null)"
+  [ -z "$sk_current" ] || printf '(⎈ %s) ' "$sk_current"
+}
+
+if [ -z "${SK_NO_PROMPT-}" ]; then
+  setopt prompt_subst
+  case "$PROMPT" in
+    *__sk_ps1*) ;;
+    *) PROMPT='$(__sk_ps1)'"$PROMPT" ;;
+  esac
+fi
+
+_sk() {
+  local -a sk_candidates
+  sk_candidates=("${(@f)$(command sk __complete "${(@)words[1,CURRENT]}" 2>/dev/null)}")
+  sk_candidates=(${sk_candidates:#})
+  (( ${#sk_candidates} )) && compadd -U -Q -V sk -- "${sk_candidates[@]}"
+}
+if (( $+functions[compdef] )); then
+  compdef _sk sk
+fi
+`
+
+const fishInit = `# sk shell integration. Add to ~/.config/fish/config.fish:
+#   sk init fish | source
+function sk
+    if contains -- "$argv[1]" exec shell
+        command sk $argv
+        return $status
+    end
+    # sk writes the new KUBECONFIG to fd 3, its output goes straight through
+    set -l sk_kubeconfig
+    set -l sk_status
+    begin
+        set sk_kubeconfig (SK_EXPORT_FD=3 command sk $argv 3>&1 1>&4 4>&-)
+        set sk_status $status
+    end 4>&1
+    test -n "$sk_kubeconfig"; and set -gx KUBECONFIG $sk_kubeconfig
+    return $sk_status
+end
+
+function __sk_prompt_segment
+    set -l sk_current (command sk __prompt 2>/dev/null)
+    test -n "$sk_current"; and printf '(⎈ %s) ' $sk_current
+end
+
+if not set -q SK_NO_PROMPT; and not functions -q __sk_original_fish_prompt
+    functions -c fish_prompt __sk_original_fish_prompt
+    function fish_prompt
+        __sk_prompt_segment
+        __sk_original_fish_prompt
+    end
+end
+
+complete -c sk -f -k -a '(command sk __complete (commandline -opc) (commandline -ct) 2>/dev/null)'
+`
+
+// printShellInit prints the integration script for the shell named in args.
+func printShellInit(args []string) error {
+	shells := []string{"bash", "fish", "zsh"}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: sk init %s", strings.Join(shells, "|"))
+	}
+	script, ok := shellInitScripts[args[0]]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q, use one of: %s", args[0], strings.Join(shells, ", "))
+	}
+	fmt.Print(script)
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellInitScripts_WireUpSessionPromptAndCompletion(t *testing.T) {
+	for shell, script := range shellInitScripts {
+		t.Run(shell, func(t *testing.T) {
+			assert.Contains(t, script, exportFdEnv+"=3 command sk")
+			assert.Contains(t, script, "command sk __prompt")
+			assert.Contains(t, script, "command sk __complete")
+		})
+	}
+}
+
+func TestPrintShellInit_RejectsUnknownShell(t *testing.T) {
+	assert.EqualError(t, printShellInit([]string{"tcsh"}), `unsupported shell "tcsh", use one of: bash, fish, zsh`)
+	assert.EqualError(t, printShellInit(nil), "usage: sk init bash|fish|zsh")
+}
+
+func TestBashCompletion_KeepsColonsInWords(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil || runtime.GOOS == "windows" {
+		t.Skip("needs bash and a POSIX sh")
+	}
+	// Stands in for sk, offering EKS style context names
+	bin := t.TempDir()
+	stub := "#!/bin/sh\n[ \"$2\" = sk ] && [ \"$3\" = arn:aws:eks:eu-west-1 ] || exit 1\nprintf '%s\\n' arn:aws:eks:eu-west-1:1:cluster/prod arn:aws:eks:eu-west-1:1:cluster/staging\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "sk"), []byte(stub), 0o700))
+
+	script := bashInit + `
+COMP_LINE='sk arn:aws:eks:eu-west-1'
+COMP_POINT=${#COMP_LINE}
+__sk_complete
+printf '%s\n' "${COMPREPLY[@]}"
+`
+	cmd := exec.Command(bash, "-c", script)
+	cmd.Env = append(os.Environ(), "PATH="+bin+string(filepath.ListSeparator)+os.Getenv("PATH"), "SK_NO_PROMPT=1")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1:1:cluster/prod\neu-west-1:1:cluster/staging\n", string(out))
+}
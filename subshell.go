@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// shellPinEnv is set to "context/namespace" inside "sk shell", so prompts can
+// tell the shell is pinned.
+const shellPinEnv = "SK_SHELL"
+
+// runShell implements "sk shell <context|favorite> [namespace]": it starts
+// $SHELL with its own copy of the kubeconfig, set to the given context and
+// namespace, and removes the copy once the shell exits, also when it's ended
+// by closing the terminal. It returns the exit code of the shell.
+func runShell(args []string) int {
+	if len(args) < 1 || len(args) > 2 {
+		fail("Usage: sk shell <context|favorite> [namespace]")
+	}
+
+	rawConfig, err := loadConfig().RawConfig()
+	checkErr(err)
+	target, err := resolveExecTarget(rawConfig, readFavorites(), args[0])
+	checkErr(err)
+	if len(args) == 2 {
+		if !validNamespaceName(args[1]) {
+			fail(fmt.Sprintf("'%s' is not a valid namespace name", args[1]))
+		}
+		target.Namespace = args[1]
+	}
+
+	kubeConfigCopy, err := writeKubeConfigCopy(rawConfig, target.Context, target.Namespace)
+	checkErr(err)
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	pin := fmt.Sprintf("%s/%s", target.Context, target.Namespace)
+	fmt.Fprintf(os.Stderr, "Starting %s in %s, exit it to return\n", shell, pin)
+	code, err := startShell(shell, pin, kubeConfigCopy)
+	// Not deferred, checkErr exits without running deferred calls
+	os.Remove(kubeConfigCopy)
+	checkErr(err)
+	return code
+}
+
+// startShell runs shell pinned to kubeConfig and returns its exit code once
+// it has exited. sk stays around until then to clean up: Ctrl-C is left to
+// the shell, and a hangup, when the terminal is closed, or a termination is
+// passed on to it so it exits first.
+func startShell(shell, pin, kubeConfig string) (int, error) {
+	cmd := exec.Command(shell)
+	cmd.Env = append(slices.DeleteFunc(os.Environ(), func(kv string) bool {
+		return strings.HasPrefix(kv, "KUBECONFIG=") || strings.HasPrefix(kv, shellPinEnv+"=")
+	}), "KUBECONFIG="+kubeConfig, shellPinEnv+"="+pin)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGHUP, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig != os.Interrupt {
+					_ = cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// writeKubeConfigCopy writes the merged kubeconfig, with contextName and
+// namespace selected, to a new temporary file. Changes made through the copy
+// never reach the user's kubeconfig. The caller removes the file.
+func writeKubeConfigCopy(rawConfig api.Config, contextName, namespace string) (string, error) {
+	c := rawConfig.DeepCopy()
+	if err := selectContextAndNamespace(c, contextName, namespace); err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp("", "sk-shell-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err = clientcmd.WriteToFile(*c, f.Name()); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestWriteKubeConfigCopy_SelectsTargetAndLeavesKubeConfigUntouched(t *testing.T) {
+	shared := writeKubeConfigFile(t, t.TempDir(), "config", "prod-eu", "prod-eu", "staging")
+	setKubeConfigPath(t, shared)
+	before, err := os.ReadFile(shared)
+	require.NoError(t, err)
+	rawConfig := loadRawConfig(t)
+
+	kubeConfigCopy, err := writeKubeConfigCopy(rawConfig, "staging", "payments")
+	require.NoError(t, err)
+	defer os.Remove(kubeConfigCopy)
+
+	c, err := clientcmd.LoadFromFile(kubeConfigCopy)
+	require.NoError(t, err)
+	assert.Equal(t, "staging", c.CurrentContext)
+	assert.Equal(t, "payments", c.Contexts["staging"].Namespace)
+	assert.Equal(t, "https://prod-eu.example.com", c.Clusters["prod-eu"].Server)
+
+	// The loaded config isn't changed either
+	assert.Equal(t, "prod-eu", rawConfig.CurrentContext)
+	assert.Equal(t, "default", rawConfig.Contexts["staging"].Namespace)
+	after, err := os.ReadFile(shared)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+
+	info, err := os.Stat(kubeConfigCopy)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestWriteKubeConfigCopy_UnknownContext(t *testing.T) {
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "prod-eu", "prod-eu"))
+
+	_, err := writeKubeConfigCopy(loadRawConfig(t), "nope", "default")
+	assert.EqualError(t, err, `context "nope" not found in kubeconfig`)
+}
+
+func TestSwitchInShell_IsNotJournaled(t *testing.T) {
+	setSkDir(t)
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "sk-shell-1.yaml", "ctx-a", "ctx-a", "ctx-b"))
+	t.Setenv(shellPinEnv, "ctx-a/default")
+
+	require.NoError(t, applyContextChange(loadRawConfig(t), "ctx-b"))
+
+	assert.Equal(t, "ctx-b", loadRawConfig(t).CurrentContext)
+	assert.Empty(t, readJournal())
+}
+
+func TestPrintPrompt_MarksPinnedShell(t *testing.T) {
+	setSkDir(t)
+	setKubeConfigPath(t, writeKubeConfigFile(t, t.TempDir(), "config", "prod-eu", "prod-eu"))
+
+	assert.Equal(t, "prod-eu/default\n", captureStdout(t, printPrompt))
+	t.Setenv(shellPinEnv, "prod-eu/default")
+	assert.Equal(t, "shell:prod-eu/default\n", captureStdout(t, printPrompt))
+}
+
+func TestStartShell_PassesTerminationOn(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs POSIX signals")
+	}
+	// Stands in for a shell, and gets sk terminated while it runs
+	shell := filepath.Join(t.TempDir(), "shell")
+	require.NoError(t, os.WriteFile(shell, []byte("#!/bin/sh\nkill -TERM $PPID\nexec sleep 10\n"), 0o700))
+
+	start := time.Now()
+	code, err := startShell(shell, "prod-eu/default", "unused")
+	require.NoError(t, err)
+	assert.NotZero(t, code)
+	assert.Less(t, time.Since(start), 5*time.Second, "the shell should have been terminated")
+}